@@ -22,7 +22,8 @@ const (
 	tabClients  = 2
 	tabLogs     = 3
 	tabSettings = 4
-	totalTabs   = 5
+	tabDevices  = 5
+	totalTabs   = 6
 
 	colorPrimary   = "205"
 	colorSecondary = "240"
@@ -94,7 +95,7 @@ type dashModel struct {
 
 func newDashModelWithConfig(ctx *tui.Context, tickInterval time.Duration) dashModel {
 	tabs := createAllTabs(ctx)
-	tabNames := []string{"Generate", "Users", "Clients", "Logs", "Settings"}
+	tabNames := []string{"Generate", "Users", "Clients", "Logs", "Settings", "Devices"}
 
 	return dashModel{
 		ctx:          ctx,
@@ -114,6 +115,7 @@ func createAllTabs(ctx *tui.Context) []tea.Model {
 		tabs.NewClientsTab(ctx),
 		tabs.NewLogsTab(ctx),
 		tabs.NewSettingsTab(ctx),
+		tabs.NewDevicesTab(ctx),
 	}
 }
 
@@ -183,7 +185,7 @@ func (m dashModel) handleGlobalKeys(key tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "?":
 		m.showHelp = !m.showHelp
 		return m, nil
-	case "1", "2", "3", "4", "5":
+	case "1", "2", "3", "4", "5", "6":
 		if checker, ok := m.tabs[m.activeTab].(TextInputChecker); ok && checker.IsTextInputActive() {
 			return m.routeToActiveTab(key)
 		}
@@ -299,7 +301,7 @@ func (m dashModel) renderActiveTabContent() string {
 }
 
 func (m dashModel) renderFooter() string {
-	return "\n" + m.theme.Faint.Render("1-5 switch tabs • ? help • q quit")
+	return "\n" + m.theme.Faint.Render("1-6 switch tabs • ? help • q quit")
 }
 
 func (m dashModel) renderHelpView() string {
@@ -318,7 +320,7 @@ func (m dashModel) renderHelpView() string {
 func (m dashModel) buildGlobalHelp() string {
 	helpLines := []string{
 		"Global Keys:",
-		"  1-5         switch to tab 1-5",
+		"  1-6         switch to tab 1-6",
 		"  ?           toggle help",
 		"  q / Ctrl+C  quit",
 		"",
@@ -2,16 +2,20 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"jwtea/internal/core"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
 	"time"
 
 	"jwtea/internal/config"
 	jwthttp "jwtea/internal/http"
 	"jwtea/internal/keys"
+	"jwtea/internal/pki"
 	"jwtea/internal/tui"
 
 	"github.com/spf13/cobra"
@@ -73,11 +77,7 @@ func loadEffectiveConfig() (*config.Config, error) {
 
 func seedStore(s *core.Store, cfg *config.Config) {
 	for _, u := range cfg.Users {
-		s.AddUser(core.User{
-			Email: u.Email,
-			Role:  u.Role,
-			Dept:  u.Dept,
-		})
+		s.AddUser(u.User())
 		log.Printf("Loaded user: %s (%s)", u.Email, u.Role)
 	}
 
@@ -85,9 +85,14 @@ func seedStore(s *core.Store, cfg *config.Config) {
 		s.AddClient(c)
 		log.Printf("Loaded client: %s", c.ID)
 	}
+
+	for _, r := range cfg.Resources {
+		s.AddResource(r.Resource())
+		log.Printf("Loaded resource: %s", r.Identifier)
+	}
 }
 
-func handleShutdown(srv *http.Server, errCh <-chan error, dashboardQuit, dashboardDone chan struct{}) error {
+func handleShutdown(servers []*http.Server, errCh <-chan error, dashboardQuit, dashboardDone chan struct{}) error {
 	select {
 	case err := <-errCh:
 		log.Printf("Server error: %v", err)
@@ -100,8 +105,10 @@ func handleShutdown(srv *http.Server, errCh <-chan error, dashboardQuit, dashboa
 
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server shutdown: %w", err)
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			return fmt.Errorf("server shutdown: %w", err)
+		}
 	}
 
 	log.Println("Server stopped cleanly")
@@ -117,7 +124,27 @@ var serveCmd = &cobra.Command{
 			return err
 		}
 
-		privKey, kid, jwk := keys.MustGenerateRSA()
+		ks, err := keys.LoadStore(cfg.Keys.Dir, cfg.Tokens.Algorithm, cfg.Keys.Generate)
+		if err != nil {
+			return fmt.Errorf("load signing keys: %w", err)
+		}
+		if cfg.Keys.Dir != "" {
+			log.Printf("Signing key %s loaded from %s", ks.Active().ID, cfg.Keys.Dir)
+		}
+		ks.SetGracePeriod(cfg.Keys.Rotation.GracePeriod.Duration)
+		if _, err := ks.PrepareNext(); err != nil {
+			log.Printf("Failed to stage next signing key: %v", err)
+		}
+
+		rotationStop := make(chan struct{})
+		defer close(rotationStop)
+		go ks.RotateEvery(cfg.Keys.Rotation.Interval.Duration, rotationStop, func(k *keys.Key, err error) {
+			if err != nil {
+				log.Printf("Key rotation failed: %v", err)
+				return
+			}
+			log.Printf("Rotated signing key, now signing with %s", k.ID)
+		})
 
 		issuer := jwthttp.DeriveIssuer(cfg.OAuth.Issuer, cfg.Server.Host, cfg.Server.Port)
 		cfg.OAuth.Issuer = issuer
@@ -128,19 +155,26 @@ var serveCmd = &cobra.Command{
 		s := core.NewStore()
 		seedStore(s, cfg)
 
+		var ca *pki.CA
+		if cfg.MTLS.Enabled {
+			ca, err = pki.LoadCA(cfg.MTLS.CADir)
+			if err != nil {
+				return fmt.Errorf("load mTLS CA: %w", err)
+			}
+		}
+
 		if cfg.CallbackServer.Enabled {
 			log.Printf("Registered callback endpoint at %s", cfg.CallbackServer.Path)
 		}
 
 		handler := jwthttp.NewRouter(jwthttp.RouterConfig{
-			Store:   s,
-			Config:  cfg,
-			Chaos:   chaosFlags,
-			LogHub:  logHub,
-			Issuer:  issuer,
-			PrivKey: privKey,
-			Kid:     kid,
-			JWK:     jwk,
+			Store:  s,
+			Config: cfg,
+			Chaos:  chaosFlags,
+			LogHub: logHub,
+			Issuer: issuer,
+			Keys:   ks,
+			CA:     ca,
 		})
 
 		addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -153,20 +187,33 @@ var serveCmd = &cobra.Command{
 			IdleTimeout:       idleTimeout,
 		}
 
-		errCh := make(chan error, 1)
+		servers := []*http.Server{srv}
+		errCh := make(chan error, 2)
 		go func() {
 			log.Printf("HTTP server listening on http://%s\n", addr)
 			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 				errCh <- err
 			}
-			close(errCh)
 		}()
 
+		if ca != nil {
+			mtlsSrv, err := newMTLSServer(cfg, ca, handler)
+			if err != nil {
+				return err
+			}
+			servers = append(servers, mtlsSrv)
+			go func() {
+				log.Printf("mTLS server listening on https://%s (CA in %s)\n", mtlsSrv.Addr, cfg.MTLS.CADir)
+				if err := mtlsSrv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					errCh <- err
+				}
+			}()
+		}
+
 		dashboardQuit := make(chan struct{})
 		dashboardDone := make(chan struct{})
 		tuiCtx := tui.NewContext(tui.ContextConfig{
-			PrivKey:       privKey,
-			Kid:           kid,
+			Keys:          ks,
 			Issuer:        issuer,
 			Store:         s,
 			Chaos:         chaosFlags,
@@ -174,16 +221,50 @@ var serveCmd = &cobra.Command{
 			ServerRunning: true,
 			Config:        cfg,
 			ConfigPath:    flagConfig,
+			CA:            ca,
 		})
 		go func() {
 			runDashboardWithContext(tuiCtx, dashboardQuit)
 			close(dashboardDone)
 		}()
 
-		return handleShutdown(srv, errCh, dashboardQuit, dashboardDone)
+		return handleShutdown(servers, errCh, dashboardQuit, dashboardDone)
 	},
 }
 
+// newMTLSServer builds the mutual-TLS listener. It serves the same handler
+// with a server certificate from the local CA, and asks for (but does not
+// itself verify) client certificates: self-signed ones are legitimate for
+// self_signed_tls_client_auth, so chains are checked per client.
+func newMTLSServer(cfg *config.Config, ca *pki.CA, handler http.Handler) (*http.Server, error) {
+	hosts := []string{cfg.Server.Host}
+	for _, h := range []string{"localhost", "127.0.0.1"} {
+		if h != cfg.Server.Host {
+			hosts = append(hosts, h)
+		}
+	}
+	if u, err := url.Parse(cfg.MTLS.BaseURL); err == nil && u.Hostname() != "" && !slices.Contains(hosts, u.Hostname()) {
+		hosts = append(hosts, u.Hostname())
+	}
+	cert, err := ca.ServerCertificate(hosts)
+	if err != nil {
+		return nil, fmt.Errorf("issue mTLS server certificate: %w", err)
+	}
+	return &http.Server{
+		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.MTLS.Port),
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequestClientCert,
+			MinVersion:   tls.VersionTLS12,
+		},
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}, nil
+}
+
 var (
 	flagLogBuffer int
 	flagConfig    string
@@ -16,14 +16,25 @@ type Config struct {
 	Server            ServerConfig        `yaml:"server"`
 	OAuth             OAuthConfig         `yaml:"oauth"`
 	Tokens            TokenConfig         `yaml:"tokens"`
+	Keys              KeysConfig          `yaml:"keys"`
+	Login             LoginConfig         `yaml:"login"`
+	Session           SessionConfig       `yaml:"session"`
+	Logout            LogoutConfig        `yaml:"logout"`
+	Device            DeviceConfig        `yaml:"device"`
+	PAR               PARConfig           `yaml:"par"`
+	DPoP              DPoPConfig          `yaml:"dpop"`
+	MTLS              MTLSConfig          `yaml:"mtls"`
 	Introspection     IntrospectionConfig `yaml:"introspection"`
 	Revocation        RevocationConfig    `yaml:"revocation"`
+	Registration      RegistrationConfig  `yaml:"registration"`
 	Users             []UserConfig        `yaml:"users"`
 	Clients           []core.Client       `yaml:"clients"`
+	Resources         []ResourceConfig    `yaml:"resources,omitempty"`
 	CallbackServer    CallbackServer      `yaml:"callback_server"`
 	ExternalCallbacks []string            `yaml:"external_callbacks"`
 	Dashboard         DashboardConfig     `yaml:"dashboard"`
 	Logging           LoggingConfig       `yaml:"logging"`
+	Admin             AdminConfig         `yaml:"admin"`
 }
 
 type ServerConfig struct {
@@ -37,8 +48,13 @@ type OAuthConfig struct {
 	DefaultScopes         []string `yaml:"default_scopes"`
 	SupportedScopes       []string `yaml:"supported_scopes"`
 	AllowedGrantTypes     []string `yaml:"allowed_grant_types"`
+	AllowedResponseTypes  []string `yaml:"allowed_response_types"`
 	PKCERequired          bool     `yaml:"pkce_required"`
 	PKCERequiredForPublic bool     `yaml:"pkce_required_for_public"`
+	// AuthorizationDetailsTypes are the authorization_details types (RFC
+	// 9396) clients may request. Rich authorization requests are refused
+	// when it is empty.
+	AuthorizationDetailsTypes []string `yaml:"authorization_details_types,omitempty"`
 }
 
 type TokenConfig struct {
@@ -49,12 +65,155 @@ type TokenConfig struct {
 	CustomClaims         map[string]string `yaml:"custom_claims"`
 	IssueRefreshToken    bool              `yaml:"issue_refresh_token"`
 	RefreshTokenRotation bool              `yaml:"refresh_token_rotation"`
+	RFC9068              RFC9068Config     `yaml:"rfc9068"`
+}
+
+// RFC9068Config selects the JWT profile for access tokens (RFC 9068).
+// DefaultResource is the aud of tokens requested without a resource; it
+// defaults to the issuer. ACR is the acr of tokens issued after a login.
+type RFC9068Config struct {
+	Enabled         bool   `yaml:"enabled"`
+	DefaultResource string `yaml:"default_resource,omitempty"`
+	ACR             string `yaml:"acr"`
+}
+
+type KeysConfig struct {
+	Dir      string         `yaml:"dir"`
+	Generate bool           `yaml:"generate"`
+	Rotation RotationConfig `yaml:"rotation"`
+}
+
+type RotationConfig struct {
+	Interval    Duration `yaml:"interval"`
+	GracePeriod Duration `yaml:"grace_period"`
+}
+
+type LoginConfig struct {
+	AutoLogin     bool     `yaml:"auto_login"`
+	Password      string   `yaml:"password"`
+	SkipConsent   bool     `yaml:"skip_consent"`
+	RequestExpiry Duration `yaml:"request_expiry"`
+}
+
+type SessionConfig struct {
+	Lifetime Duration `yaml:"lifetime"`
+}
+
+type LogoutConfig struct {
+	RevokeRefreshTokens bool `yaml:"revoke_refresh_tokens"`
+}
+
+type DeviceConfig struct {
+	CodeExpiry Duration `yaml:"code_expiry"`
+	Interval   Duration `yaml:"interval"`
+}
+
+type DPoPConfig struct {
+	ProofLifetime Duration `yaml:"proof_lifetime"`
+	RequireNonce  bool     `yaml:"require_nonce"`
+}
+
+// MTLSConfig configures the mutual-TLS listener (RFC 8705). BaseURL is the
+// listener's external URL; it defaults to https://<server.host>:<port>.
+type MTLSConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    int    `yaml:"port"`
+	CADir   string `yaml:"ca_dir"`
+	BaseURL string `yaml:"base_url"`
+}
+
+type PARConfig struct {
+	RequestExpiry Duration `yaml:"request_expiry"`
+	Required      bool     `yaml:"required"`
 }
 
 type UserConfig struct {
-	Email string `yaml:"email"`
-	Role  string `yaml:"role"`
-	Dept  string `yaml:"dept"`
+	Email               string   `yaml:"email"`
+	Role                string   `yaml:"role"`
+	Dept                string   `yaml:"dept"`
+	Name                string   `yaml:"name,omitempty"`
+	GivenName           string   `yaml:"given_name,omitempty"`
+	FamilyName          string   `yaml:"family_name,omitempty"`
+	PreferredUsername   string   `yaml:"preferred_username,omitempty"`
+	Picture             string   `yaml:"picture,omitempty"`
+	Locale              string   `yaml:"locale,omitempty"`
+	EmailVerified       bool     `yaml:"email_verified,omitempty"`
+	PhoneNumber         string   `yaml:"phone_number,omitempty"`
+	PhoneNumberVerified bool     `yaml:"phone_number_verified,omitempty"`
+	Address             string   `yaml:"address,omitempty"`
+	Groups              []string `yaml:"groups,omitempty"`
+	Entitlements        []string `yaml:"entitlements,omitempty"`
+	// Password is a plain text convenience for hand-written configs; it is
+	// hashed on load and saved back as PasswordHash.
+	Password     string `yaml:"password,omitempty"`
+	PasswordHash string `yaml:"password_hash,omitempty"`
+}
+
+// User converts the configured user into a store user.
+func (u UserConfig) User() core.User {
+	hash := u.PasswordHash
+	if hash == "" && u.Password != "" {
+		// Hashing only fails if the system random source does.
+		hash, _ = core.HashPassword(u.Password)
+	}
+	return core.User{
+		Email:               u.Email,
+		Role:                u.Role,
+		Dept:                u.Dept,
+		Name:                u.Name,
+		GivenName:           u.GivenName,
+		FamilyName:          u.FamilyName,
+		PreferredUsername:   u.PreferredUsername,
+		Picture:             u.Picture,
+		Locale:              u.Locale,
+		EmailVerified:       u.EmailVerified,
+		PhoneNumber:         u.PhoneNumber,
+		PhoneNumberVerified: u.PhoneNumberVerified,
+		Address:             u.Address,
+		Groups:              u.Groups,
+		Entitlements:        u.Entitlements,
+		PasswordHash:        hash,
+	}
+}
+
+// ResourceConfig registers a protected resource (API) that clients can ask
+// tokens for with the resource parameter.
+type ResourceConfig struct {
+	Identifier        string   `yaml:"identifier"`
+	Name              string   `yaml:"name,omitempty"`
+	Scopes            []string `yaml:"scopes,omitempty"`
+	AccessTokenExpiry Duration `yaml:"access_token_expiry,omitempty"`
+}
+
+// Resource converts the configured resource into a store resource.
+func (r ResourceConfig) Resource() core.Resource {
+	return core.Resource{
+		Identifier:        r.Identifier,
+		Name:              r.Name,
+		Scopes:            r.Scopes,
+		AccessTokenExpiry: r.AccessTokenExpiry.Duration,
+	}
+}
+
+func userConfigFrom(u core.User) UserConfig {
+	return UserConfig{
+		Email:               u.Email,
+		Role:                u.Role,
+		Dept:                u.Dept,
+		Name:                u.Name,
+		GivenName:           u.GivenName,
+		FamilyName:          u.FamilyName,
+		PreferredUsername:   u.PreferredUsername,
+		Picture:             u.Picture,
+		Locale:              u.Locale,
+		EmailVerified:       u.EmailVerified,
+		PhoneNumber:         u.PhoneNumber,
+		PhoneNumberVerified: u.PhoneNumberVerified,
+		Address:             u.Address,
+		Groups:              u.Groups,
+		Entitlements:        u.Entitlements,
+		PasswordHash:        u.PasswordHash,
+	}
 }
 
 type CallbackServer struct {
@@ -77,6 +236,10 @@ type LoggingConfig struct {
 	BufferSize int    `yaml:"buffer_size"`
 }
 
+type AdminConfig struct {
+	Token string `yaml:"token"`
+}
+
 type IntrospectionConfig struct {
 	Enabled           bool     `yaml:"enabled"`
 	RequireClientAuth bool     `yaml:"require_client_auth"`
@@ -88,6 +251,11 @@ type RevocationConfig struct {
 	RequireClientAuth bool `yaml:"require_client_auth"`
 }
 
+type RegistrationConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	InitialAccessToken string `yaml:"initial_access_token"`
+}
+
 type Duration struct {
 	time.Duration
 }
@@ -153,14 +321,25 @@ func (c *Config) applyDefaults() {
 		c.OAuth.DefaultScopes = []string{"openid"}
 	}
 	if len(c.OAuth.SupportedScopes) == 0 {
-		c.OAuth.SupportedScopes = []string{"openid", "profile", "email"}
+		c.OAuth.SupportedScopes = []string{"openid", "profile", "email", "phone", "address"}
 	}
 	if len(c.OAuth.AllowedGrantTypes) == 0 {
-		c.OAuth.AllowedGrantTypes = []string{"authorization_code", "client_credentials", "refresh_token"}
+		c.OAuth.AllowedGrantTypes = []string{"authorization_code", "client_credentials", "refresh_token", "urn:ietf:params:oauth:grant-type:device_code"}
+	}
+	if len(c.OAuth.AllowedResponseTypes) == 0 {
+		c.OAuth.AllowedResponseTypes = []string{"code", "token", "id_token", "id_token token", "code id_token", "code token", "code id_token token"}
 	}
 	if len(c.OAuth.SupportedScopes) > 0 && !containsScope(c.OAuth.SupportedScopes, "offline_access") {
 		c.OAuth.SupportedScopes = append(c.OAuth.SupportedScopes, "offline_access")
 	}
+	// Scopes a resource offers are supported without listing them twice.
+	for _, r := range c.Resources {
+		for _, s := range r.Scopes {
+			if !containsScope(c.OAuth.SupportedScopes, s) {
+				c.OAuth.SupportedScopes = append(c.OAuth.SupportedScopes, s)
+			}
+		}
+	}
 
 	if c.Tokens.AccessTokenExpiry.Duration == 0 {
 		c.Tokens.AccessTokenExpiry.Duration = 5 * time.Minute
@@ -174,6 +353,40 @@ func (c *Config) applyDefaults() {
 	if c.Tokens.Algorithm == "" {
 		c.Tokens.Algorithm = "RS256"
 	}
+	if c.Tokens.RFC9068.ACR == "" {
+		c.Tokens.RFC9068.ACR = "0"
+	}
+
+	if c.Keys.Rotation.GracePeriod.Duration == 0 {
+		c.Keys.Rotation.GracePeriod.Duration = 1 * time.Hour
+	}
+
+	if c.Login.RequestExpiry.Duration == 0 {
+		c.Login.RequestExpiry.Duration = 10 * time.Minute
+	}
+
+	if c.Session.Lifetime.Duration == 0 {
+		c.Session.Lifetime.Duration = 8 * time.Hour
+	}
+
+	if c.Device.CodeExpiry.Duration == 0 {
+		c.Device.CodeExpiry.Duration = 10 * time.Minute
+	}
+	if c.Device.Interval.Duration == 0 {
+		c.Device.Interval.Duration = 5 * time.Second
+	}
+	if c.PAR.RequestExpiry.Duration == 0 {
+		c.PAR.RequestExpiry.Duration = 90 * time.Second
+	}
+	if c.DPoP.ProofLifetime.Duration == 0 {
+		c.DPoP.ProofLifetime.Duration = time.Minute
+	}
+	if c.MTLS.Port == 0 {
+		c.MTLS.Port = 8443
+	}
+	if c.MTLS.CADir == "" {
+		c.MTLS.CADir = ".jwtea/ca"
+	}
 
 	if c.Dashboard.TickInterval.Duration == 0 {
 		c.Dashboard.TickInterval.Duration = 1000 * time.Millisecond
@@ -214,9 +427,9 @@ func (c *Config) applyDefaults() {
 
 	if len(c.Users) == 0 {
 		c.Users = []UserConfig{
-			{Email: "alice@test.com", Role: "user", Dept: "engineering"},
-			{Email: "bob@test.com", Role: "user", Dept: "sales"},
-			{Email: "admin@test.com", Role: "admin", Dept: ""},
+			{Email: "alice@test.com", Role: "user", Dept: "engineering", Name: "Alice Anderson", GivenName: "Alice", FamilyName: "Anderson", EmailVerified: true},
+			{Email: "bob@test.com", Role: "user", Dept: "sales", Name: "Bob Brown", GivenName: "Bob", FamilyName: "Brown", EmailVerified: true},
+			{Email: "admin@test.com", Role: "admin", Dept: "", Name: "Admin", EmailVerified: true},
 		}
 	}
 
@@ -278,6 +491,9 @@ func (c *Config) applyEnvOverrides() {
 	if scopes := os.Getenv("JWTEA_OAUTH_SUPPORTED_SCOPES"); scopes != "" {
 		c.OAuth.SupportedScopes = strings.Split(scopes, ",")
 	}
+	if types := os.Getenv("JWTEA_OAUTH_AUTHORIZATION_DETAILS_TYPES"); types != "" {
+		c.OAuth.AuthorizationDetailsTypes = strings.Split(types, ",")
+	}
 
 	if expiry := os.Getenv("JWTEA_TOKENS_ACCESS_TOKEN_EXPIRY"); expiry != "" {
 		if d, err := time.ParseDuration(expiry); err == nil {
@@ -297,6 +513,103 @@ func (c *Config) applyEnvOverrides() {
 	if algo := os.Getenv("JWTEA_TOKENS_ALGORITHM"); algo != "" {
 		c.Tokens.Algorithm = algo
 	}
+	if enabled := os.Getenv("JWTEA_TOKENS_RFC9068_ENABLED"); enabled != "" {
+		c.Tokens.RFC9068.Enabled = enabled == "true" || enabled == "1"
+	}
+	if resource := os.Getenv("JWTEA_TOKENS_RFC9068_DEFAULT_RESOURCE"); resource != "" {
+		c.Tokens.RFC9068.DefaultResource = resource
+	}
+
+	if dir := os.Getenv("JWTEA_KEYS_DIR"); dir != "" {
+		c.Keys.Dir = dir
+	}
+	if generate := os.Getenv("JWTEA_KEYS_GENERATE"); generate != "" {
+		c.Keys.Generate = generate == "true" || generate == "1"
+	}
+	if interval := os.Getenv("JWTEA_KEYS_ROTATION_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			c.Keys.Rotation.Interval.Duration = d
+		}
+	}
+	if grace := os.Getenv("JWTEA_KEYS_ROTATION_GRACE_PERIOD"); grace != "" {
+		if d, err := time.ParseDuration(grace); err == nil {
+			c.Keys.Rotation.GracePeriod.Duration = d
+		}
+	}
+	if token := os.Getenv("JWTEA_ADMIN_TOKEN"); token != "" {
+		c.Admin.Token = token
+	}
+
+	if enabled := os.Getenv("JWTEA_REGISTRATION_ENABLED"); enabled != "" {
+		c.Registration.Enabled = enabled == "true" || enabled == "1"
+	}
+	if token := os.Getenv("JWTEA_REGISTRATION_INITIAL_ACCESS_TOKEN"); token != "" {
+		c.Registration.InitialAccessToken = token
+	}
+
+	if autoLogin := os.Getenv("JWTEA_LOGIN_AUTO_LOGIN"); autoLogin != "" {
+		c.Login.AutoLogin = autoLogin == "true" || autoLogin == "1"
+	}
+	if password := os.Getenv("JWTEA_LOGIN_PASSWORD"); password != "" {
+		c.Login.Password = password
+	}
+	if skip := os.Getenv("JWTEA_LOGIN_SKIP_CONSENT"); skip != "" {
+		c.Login.SkipConsent = skip == "true" || skip == "1"
+	}
+
+	if lifetime := os.Getenv("JWTEA_SESSION_LIFETIME"); lifetime != "" {
+		if d, err := time.ParseDuration(lifetime); err == nil {
+			c.Session.Lifetime.Duration = d
+		}
+	}
+
+	if revoke := os.Getenv("JWTEA_LOGOUT_REVOKE_REFRESH_TOKENS"); revoke != "" {
+		c.Logout.RevokeRefreshTokens = revoke == "true" || revoke == "1"
+	}
+
+	if expiry := os.Getenv("JWTEA_DEVICE_CODE_EXPIRY"); expiry != "" {
+		if d, err := time.ParseDuration(expiry); err == nil {
+			c.Device.CodeExpiry.Duration = d
+		}
+	}
+	if interval := os.Getenv("JWTEA_DEVICE_INTERVAL"); interval != "" {
+		if d, err := time.ParseDuration(interval); err == nil {
+			c.Device.Interval.Duration = d
+		}
+	}
+
+	if expiry := os.Getenv("JWTEA_PAR_REQUEST_EXPIRY"); expiry != "" {
+		if d, err := time.ParseDuration(expiry); err == nil {
+			c.PAR.RequestExpiry.Duration = d
+		}
+	}
+	if required := os.Getenv("JWTEA_PAR_REQUIRED"); required != "" {
+		c.PAR.Required = required == "true" || required == "1"
+	}
+
+	if lifetime := os.Getenv("JWTEA_DPOP_PROOF_LIFETIME"); lifetime != "" {
+		if d, err := time.ParseDuration(lifetime); err == nil {
+			c.DPoP.ProofLifetime.Duration = d
+		}
+	}
+	if nonce := os.Getenv("JWTEA_DPOP_REQUIRE_NONCE"); nonce != "" {
+		c.DPoP.RequireNonce = nonce == "true" || nonce == "1"
+	}
+
+	if enabled := os.Getenv("JWTEA_MTLS_ENABLED"); enabled != "" {
+		c.MTLS.Enabled = enabled == "true" || enabled == "1"
+	}
+	if port := os.Getenv("JWTEA_MTLS_PORT"); port != "" {
+		if p, err := strconv.Atoi(port); err == nil {
+			c.MTLS.Port = p
+		}
+	}
+	if dir := os.Getenv("JWTEA_MTLS_CA_DIR"); dir != "" {
+		c.MTLS.CADir = dir
+	}
+	if baseURL := os.Getenv("JWTEA_MTLS_BASE_URL"); baseURL != "" {
+		c.MTLS.BaseURL = baseURL
+	}
 
 	if enabled := os.Getenv("JWTEA_CALLBACK_SERVER_ENABLED"); enabled != "" {
 		c.CallbackServer.Enabled = enabled == "true" || enabled == "1"
@@ -377,11 +690,7 @@ func (c *Config) SyncFromStore(s *core.Store) {
 	users := s.ListUsers()
 	c.Users = make([]UserConfig, len(users))
 	for i, u := range users {
-		c.Users[i] = UserConfig{
-			Email: u.Email,
-			Role:  u.Role,
-			Dept:  u.Dept,
-		}
+		c.Users[i] = userConfigFrom(u)
 	}
 	sort.Slice(c.Users, func(i, j int) bool {
 		return c.Users[i].Email < c.Users[j].Email
@@ -0,0 +1,65 @@
+package core
+
+import "strings"
+
+// ScopeClaims lists the user claims each standard OIDC scope releases.
+var ScopeClaims = map[string][]string{
+	"profile": {"name", "given_name", "family_name", "preferred_username", "picture", "locale"},
+	"email":   {"email", "email_verified"},
+	"phone":   {"phone_number", "phone_number_verified"},
+	"address": {"address"},
+}
+
+// UserClaims returns the claims about u that the space-separated scope
+// grants. Empty attributes are left out.
+func UserClaims(u User, scope string) map[string]any {
+	all := map[string]any{
+		"name":               u.Name,
+		"given_name":         u.GivenName,
+		"family_name":        u.FamilyName,
+		"preferred_username": u.PreferredUsername,
+		"picture":            u.Picture,
+		"locale":             u.Locale,
+		"email":              u.Email,
+		"email_verified":     u.EmailVerified,
+	}
+	if u.PhoneNumber != "" {
+		all["phone_number"] = u.PhoneNumber
+		all["phone_number_verified"] = u.PhoneNumberVerified
+	}
+	if u.Address != "" {
+		all["address"] = map[string]string{"formatted": u.Address}
+	}
+
+	claims := make(map[string]any)
+	for _, s := range strings.Fields(scope) {
+		for _, name := range ScopeClaims[s] {
+			v, ok := all[name]
+			if !ok {
+				continue
+			}
+			if str, isStr := v.(string); isStr && str == "" {
+				continue
+			}
+			claims[name] = v
+		}
+	}
+	return claims
+}
+
+// AccessTokenUserClaims returns the SCIM roles, groups and entitlements of u
+// that RFC 9068 access tokens carry (section 2.2.3.1). Empty attributes are
+// left out.
+func AccessTokenUserClaims(u User) map[string]any {
+	claims := make(map[string]any)
+	if u.Role != "" {
+		claims["roles"] = []string{u.Role}
+	}
+	if len(u.Groups) > 0 {
+		claims["groups"] = u.Groups
+	}
+	if len(u.Entitlements) > 0 {
+		claims["entitlements"] = u.Entitlements
+	}
+	return claims
+}
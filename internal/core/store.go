@@ -1,6 +1,8 @@
 package core
 
 import (
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,18 +11,36 @@ type Store struct {
 	mu            sync.Mutex
 	clients       map[string]Client
 	codes         map[string]AuthCode
+	authRequests  map[string]AuthRequest
+	sessions      map[string]Session
 	users         map[string]User
 	refreshTokens map[string]RefreshToken
 	revokedTokens map[string]RevokedToken
+	consents      map[string]Consent
+	userClients   map[string][]string
+	deviceCodes   map[string]DeviceCode
+	usedJTIs      map[string]time.Time
+	pushed        map[string]PushedRequest
+	dpopNonces    map[string]time.Time
+	resources     map[string]Resource
 }
 
 func NewStore() *Store {
 	return &Store{
 		clients:       make(map[string]Client),
 		codes:         make(map[string]AuthCode),
+		authRequests:  make(map[string]AuthRequest),
+		sessions:      make(map[string]Session),
 		users:         make(map[string]User),
 		refreshTokens: make(map[string]RefreshToken),
 		revokedTokens: make(map[string]RevokedToken),
+		consents:      make(map[string]Consent),
+		userClients:   make(map[string][]string),
+		deviceCodes:   make(map[string]DeviceCode),
+		usedJTIs:      make(map[string]time.Time),
+		resources:     make(map[string]Resource),
+		pushed:        make(map[string]PushedRequest),
+		dpopNonces:    make(map[string]time.Time),
 	}
 }
 
@@ -47,6 +67,29 @@ func (s *Store) ListClients() []Client {
 	return clients
 }
 
+func (s *Store) AddResource(r Resource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[r.Identifier] = r
+}
+
+func (s *Store) GetResource(identifier string) (Resource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.resources[identifier]
+	return r, ok
+}
+
+func (s *Store) ListResources() []Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resources := make([]Resource, 0, len(s.resources))
+	for _, r := range s.resources {
+		resources = append(resources, r)
+	}
+	return resources
+}
+
 func (s *Store) UpdateClient(c Client) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -88,6 +131,77 @@ func (s *Store) ConsumeCode(code string) (AuthCode, bool) {
 	return ac, true
 }
 
+func (s *Store) SaveAuthRequest(ar AuthRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authRequests[ar.ID] = ar
+}
+
+func (s *Store) GetAuthRequest(id string) (AuthRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ar, ok := s.authRequests[id]
+	if !ok || time.Now().After(ar.ExpiresAt) {
+		return AuthRequest{}, false
+	}
+	return ar, true
+}
+
+func (s *Store) DeleteAuthRequest(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.authRequests, id)
+}
+
+func (s *Store) SavePushedRequest(pr PushedRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pushed[pr.RequestURI] = pr
+}
+
+// TakePushedRequest returns and removes the pushed authorization request
+// clientID made; each request_uri can be used once. A request_uri presented
+// by another client is left in place.
+func (s *Store) TakePushedRequest(requestURI, clientID string) (PushedRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pr, ok := s.pushed[requestURI]
+	if !ok || pr.ClientID != clientID {
+		return PushedRequest{}, false
+	}
+	delete(s.pushed, requestURI)
+	if time.Now().After(pr.ExpiresAt) {
+		return PushedRequest{}, false
+	}
+	return pr, true
+}
+
+func (s *Store) SaveSession(sess Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sess.ID] = sess
+}
+
+func (s *Store) GetSession(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return Session{}, false
+	}
+	return sess, true
+}
+
+func (s *Store) DeleteSession(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.sessions[id]; !exists {
+		return false
+	}
+	delete(s.sessions, id)
+	return true
+}
+
 func (s *Store) AddUser(u User) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -175,12 +289,14 @@ func (s *Store) IsAccessTokenRevoked(tokenID string) bool {
 	return revoked
 }
 
+// RevokeRefreshTokensByUser revokes the user's refresh tokens for clientID,
+// or for every client when clientID is empty.
 func (s *Store) RevokeRefreshTokensByUser(userID, clientID string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	count := 0
 	for token, rt := range s.refreshTokens {
-		if rt.UserID == userID && rt.ClientID == clientID && !rt.Revoked {
+		if rt.UserID == userID && (clientID == "" || rt.ClientID == clientID) && !rt.Revoked {
 			rt.Revoked = true
 			s.refreshTokens[token] = rt
 			count++
@@ -188,3 +304,211 @@ func (s *Store) RevokeRefreshTokensByUser(userID, clientID string) int {
 	}
 	return count
 }
+
+func consentKey(userID, clientID string) string {
+	return userID + "\x00" + clientID
+}
+
+// SaveConsent records the scopes a user approved for a client, adding to any
+// scopes approved before.
+func (s *Store) SaveConsent(c Consent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := consentKey(c.UserID, c.ClientID)
+	if prev, ok := s.consents[key]; ok {
+		for _, scope := range prev.Scopes {
+			if !slices.Contains(c.Scopes, scope) {
+				c.Scopes = append(c.Scopes, scope)
+			}
+		}
+	}
+	s.consents[key] = c
+}
+
+func (s *Store) GetConsent(userID, clientID string) (Consent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.consents[consentKey(userID, clientID)]
+	return c, ok
+}
+
+func (s *Store) ListConsents(userID string) []Consent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var consents []Consent
+	for _, c := range s.consents {
+		if c.UserID == userID {
+			consents = append(consents, c)
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool {
+		return consents[i].ClientID < consents[j].ClientID
+	})
+	return consents
+}
+
+func (s *Store) RevokeConsent(userID, clientID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := consentKey(userID, clientID)
+	if _, exists := s.consents[key]; !exists {
+		return false
+	}
+	delete(s.consents, key)
+	return true
+}
+
+// TrackUserClient records that clientID obtained tokens for userID.
+func (s *Store) TrackUserClient(userID, clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.userClients[userID], clientID) {
+		s.userClients[userID] = append(s.userClients[userID], clientID)
+	}
+}
+
+// UserClients returns the clients that obtained tokens for userID.
+func (s *Store) UserClients(userID string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clients := slices.Clone(s.userClients[userID])
+	sort.Strings(clients)
+	return clients
+}
+
+// ForgetUserClients drops the tracked clients for userID.
+func (s *Store) ForgetUserClients(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.userClients, userID)
+}
+
+func (s *Store) SaveDeviceCode(dc DeviceCode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deviceCodes[dc.DeviceCode] = dc
+}
+
+// GetDeviceCodeByUserCode returns the pending, unexpired request for userCode.
+func (s *Store) GetDeviceCodeByUserCode(userCode string) (DeviceCode, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, dc := range s.deviceCodes {
+		if dc.UserCode == userCode && dc.Status == DevicePending && time.Now().Before(dc.ExpiresAt) {
+			return dc, true
+		}
+	}
+	return DeviceCode{}, false
+}
+
+// ListPendingDeviceCodes returns the unexpired requests still waiting for a
+// user, oldest first.
+func (s *Store) ListPendingDeviceCodes() []DeviceCode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	codes := make([]DeviceCode, 0)
+	for _, dc := range s.deviceCodes {
+		if dc.Status == DevicePending && now.Before(dc.ExpiresAt) {
+			codes = append(codes, dc)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].ExpiresAt.Before(codes[j].ExpiresAt)
+	})
+	return codes
+}
+
+// ApproveDeviceCode grants the pending request for userCode to userID.
+func (s *Store) ApproveDeviceCode(userCode, userID string) bool {
+	return s.resolveDeviceCode(userCode, func(dc *DeviceCode) {
+		dc.Status = DeviceApproved
+		dc.UserID = userID
+		dc.AuthTime = time.Now()
+	})
+}
+
+// DenyDeviceCode rejects the pending request for userCode.
+func (s *Store) DenyDeviceCode(userCode string) bool {
+	return s.resolveDeviceCode(userCode, func(dc *DeviceCode) {
+		dc.Status = DeviceDenied
+	})
+}
+
+func (s *Store) resolveDeviceCode(userCode string, fn func(*DeviceCode)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, dc := range s.deviceCodes {
+		if dc.UserCode == userCode && dc.Status == DevicePending && time.Now().Before(dc.ExpiresAt) {
+			fn(&dc)
+			s.deviceCodes[key] = dc
+			return true
+		}
+	}
+	return false
+}
+
+// PollDeviceCode records a token request for deviceCode. It reports whether
+// the client polled faster than the interval allows, in which case the
+// interval grows by five seconds as RFC 8628 requires.
+func (s *Store) PollDeviceCode(deviceCode string, now time.Time) (DeviceCode, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dc, ok := s.deviceCodes[deviceCode]
+	if !ok {
+		return DeviceCode{}, false, false
+	}
+	tooFast := !dc.LastPolled.IsZero() && now.Sub(dc.LastPolled) < dc.Interval
+	if tooFast {
+		dc.Interval += 5 * time.Second
+	}
+	dc.LastPolled = now
+	s.deviceCodes[deviceCode] = dc
+	return dc, tooFast, true
+}
+
+func (s *Store) DeleteDeviceCode(deviceCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deviceCodes, deviceCode)
+}
+
+// UseJTI records a JWT ID until expiresAt and reports whether it was unused,
+// so a signed assertion can't be replayed while it is still valid.
+func (s *Store) UseJTI(jti string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, exp := range s.usedJTIs {
+		if now.After(exp) {
+			delete(s.usedJTIs, id)
+		}
+	}
+	if _, used := s.usedJTIs[jti]; used {
+		return false
+	}
+	s.usedJTIs[jti] = expiresAt
+	return true
+}
+
+// SaveDPoPNonce records a nonce handed out in a DPoP-Nonce header.
+func (s *Store) SaveDPoPNonce(nonce string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for n, exp := range s.dpopNonces {
+		if now.After(exp) {
+			delete(s.dpopNonces, n)
+		}
+	}
+	s.dpopNonces[nonce] = expiresAt
+}
+
+// ValidDPoPNonce reports whether nonce was handed out and has not expired.
+// A nonce can be used more than once until then.
+func (s *Store) ValidDPoPNonce(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.dpopNonces[nonce]
+	return ok && time.Now().Before(exp)
+}
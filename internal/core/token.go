@@ -2,8 +2,10 @@ package core
 
 import (
 	"crypto/rand"
-	"crypto/rsa"
+	_ "crypto/sha512"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"time"
 
 	"jwtea/internal/keys"
@@ -18,16 +20,31 @@ func generateJTI() string {
 }
 
 type TokenGenerator struct {
-	PrivKey *rsa.PrivateKey
-	Kid     string
-	Issuer  string
+	Keys   *keys.Store
+	Issuer string
 }
 
 type TokenRequest struct {
-	Subject               string
-	Audience              string
-	Scope                 string
-	ExpiresIn             time.Duration
+	Subject              string
+	Audience             []string
+	ClientID             string
+	Scope                string
+	ExpiresIn            time.Duration
+	IDTokenExpiresIn     time.Duration
+	Nonce                string
+	Code                 string
+	IDTokenOnly          bool
+	AuthTime             time.Time
+	Actor                map[string]any
+	JKT                  string
+	X5T                  string
+	AuthorizationDetails []map[string]any
+	// RFC9068 issues the access token in the JWT profile of RFC 9068: typed
+	// at+jwt and carrying auth_time, ACR and UserAttributes.
+	RFC9068               bool
+	ACR                   string
+	UserAttributes        map[string]any
+	UserClaims            map[string]any
 	CustomClaims          map[string]any
 	ChaosExpired          bool
 	ChaosInvalidSignature bool
@@ -39,15 +56,19 @@ type TokenResult struct {
 	ExpiresIn   int64
 }
 
-func NewTokenGenerator(privKey *rsa.PrivateKey, kid, issuer string) *TokenGenerator {
+func NewTokenGenerator(ks *keys.Store, issuer string) *TokenGenerator {
 	return &TokenGenerator{
-		PrivKey: privKey,
-		Kid:     kid,
-		Issuer:  issuer,
+		Keys:   ks,
+		Issuer: issuer,
 	}
 }
 
 func (g *TokenGenerator) Generate(req TokenRequest) (*TokenResult, error) {
+	key := g.Keys.Active()
+	if key == nil {
+		return nil, errors.New("no active signing key")
+	}
+
 	now := time.Now()
 
 	atExp := now.Add(req.ExpiresIn)
@@ -58,7 +79,7 @@ func (g *TokenGenerator) Generate(req TokenRequest) (*TokenResult, error) {
 	accessClaims := jwt.MapClaims{
 		"iss": g.Issuer,
 		"sub": req.Subject,
-		"aud": req.Audience,
+		"aud": audienceClaim(req.Audience),
 		"iat": now.Unix(),
 		"exp": atExp.Unix(),
 		"jti": generateJTI(),
@@ -67,18 +88,61 @@ func (g *TokenGenerator) Generate(req TokenRequest) (*TokenResult, error) {
 	if req.Scope != "" {
 		accessClaims["scope"] = req.Scope
 	}
+	if req.Actor != nil {
+		accessClaims["act"] = req.Actor
+	}
+	cnf := map[string]any{}
+	if req.JKT != "" {
+		cnf["jkt"] = req.JKT
+	}
+	if req.X5T != "" {
+		cnf["x5t#S256"] = req.X5T
+	}
+	if len(cnf) > 0 {
+		accessClaims["cnf"] = cnf
+	}
+	if len(req.AuthorizationDetails) > 0 {
+		accessClaims["authorization_details"] = req.AuthorizationDetails
+	}
+	// aud names the resource, so the client the token was issued to is
+	// carried separately for introspection and revocation.
+	if req.ClientID != "" {
+		accessClaims["client_id"] = req.ClientID
+	}
+	if req.RFC9068 {
+		if !req.AuthTime.IsZero() {
+			accessClaims["auth_time"] = req.AuthTime.Unix()
+			if req.ACR != "" {
+				accessClaims["acr"] = req.ACR
+			}
+		}
+		for k, v := range req.UserAttributes {
+			accessClaims[k] = v
+		}
+	}
 
 	for k, v := range req.CustomClaims {
 		accessClaims[k] = v
 	}
 
-	at := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims)
-	at.Header["kid"] = g.Kid
+	method := jwt.GetSigningMethod(key.Alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", key.Alg)
+	}
+
+	at := jwt.NewWithClaims(method, accessClaims)
+	at.Header["kid"] = key.ID
+	if req.RFC9068 {
+		at.Header["typ"] = "at+jwt"
+	}
 
-	signingKey := g.PrivKey
+	signingKey := key.Private
 	if req.ChaosInvalidSignature {
-		k, _, _ := keys.MustGenerateRSA()
-		signingKey = k
+		k, err := keys.Generate(key.Alg)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = k.Private
 	}
 
 	signedAT, err := at.SignedString(signingKey)
@@ -86,21 +150,58 @@ func (g *TokenGenerator) Generate(req TokenRequest) (*TokenResult, error) {
 		return nil, err
 	}
 
-	idExp := now.Add(req.ExpiresIn)
+	idTTL := req.IDTokenExpiresIn
+	if idTTL == 0 {
+		idTTL = req.ExpiresIn
+	}
+	idExp := now.Add(idTTL)
 	if req.ChaosExpired {
 		idExp = now.Add(-1 * time.Hour)
 	}
 
+	idAud := audienceClaim(req.Audience)
+	if req.ClientID != "" {
+		idAud = req.ClientID
+	}
+	atHash, err := TokenHash(signedAT, key.Alg)
+	if err != nil {
+		return nil, err
+	}
+
 	idClaims := jwt.MapClaims{
 		"iss": g.Issuer,
 		"sub": req.Subject,
-		"aud": req.Audience,
+		"aud": idAud,
 		"iat": now.Unix(),
 		"exp": idExp.Unix(),
 	}
+	// An ID token returned without its access token (response_type=id_token)
+	// has nothing for at_hash to bind to.
+	if !req.IDTokenOnly {
+		idClaims["at_hash"] = atHash
+	}
+	if req.Code != "" {
+		cHash, err := TokenHash(req.Code, key.Alg)
+		if err != nil {
+			return nil, err
+		}
+		idClaims["c_hash"] = cHash
+	}
+	for k, v := range req.UserClaims {
+		idClaims[k] = v
+	}
+	if req.ClientID != "" {
+		idClaims["azp"] = req.ClientID
+	}
+	if req.Nonce != "" {
+		idClaims["nonce"] = req.Nonce
+	}
+	if !req.AuthTime.IsZero() {
+		idClaims["auth_time"] = req.AuthTime.Unix()
+	}
 
-	idt := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
-	idt.Header["kid"] = g.Kid
+	idt := jwt.NewWithClaims(method, idClaims)
+	idt.Header["kid"] = key.ID
 	signedIDT, err := idt.SignedString(signingKey)
 	if err != nil {
 		return nil, err
@@ -113,13 +214,69 @@ func (g *TokenGenerator) Generate(req TokenRequest) (*TokenResult, error) {
 	}, nil
 }
 
-func ParseAndValidateToken(tokenStr string, pubKey *rsa.PublicKey) (jwt.MapClaims, error) {
+// audienceClaim keeps a single audience a plain string, as tokens had before
+// multiple audiences were possible.
+func audienceClaim(aud []string) any {
+	if len(aud) == 1 {
+		return aud[0]
+	}
+	return aud
+}
+
+// Sign signs arbitrary claims, such as a logout token, with the active key.
+// typ sets the JOSE "typ" header when it is not empty.
+func (g *TokenGenerator) Sign(claims jwt.MapClaims, typ string) (string, error) {
+	key := g.Keys.Active()
+	if key == nil {
+		return "", errors.New("no active signing key")
+	}
+	method := jwt.GetSigningMethod(key.Alg)
+	if method == nil {
+		return "", fmt.Errorf("unsupported signing algorithm %q", key.Alg)
+	}
+	t := jwt.NewWithClaims(method, claims)
+	t.Header["kid"] = key.ID
+	if typ != "" {
+		t.Header["typ"] = typ
+	}
+	return t.SignedString(key.Private)
+}
+
+// TokenHash computes an OIDC token hash such as at_hash: the left half of the
+// token's digest under the hash of the signing algorithm, base64url encoded.
+func TokenHash(token, alg string) (string, error) {
+	a, err := keys.LookupAlgorithm(alg)
+	if err != nil {
+		return "", err
+	}
+	h := a.Hash.New()
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// ParseAndValidateToken verifies a token signed by one of the issuer's
+// published keys. The token's alg must be that of the key its kid selects, so
+// tokens from retired keys of an earlier tokens.algorithm stay valid during
+// the grace period. Extra parser options, such as
+// jwt.WithoutClaimsValidation, are passed to the parser.
+func ParseAndValidateToken(tokenStr string, ks *keys.Store, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+		key := ks.Active()
+		if kid, ok := t.Header["kid"].(string); ok {
+			key, ok = ks.Get(kid)
+			if !ok {
+				return nil, jwt.ErrTokenUnverifiable
+			}
+		}
+		if key == nil {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		if t.Method.Alg() != key.Alg {
 			return nil, jwt.ErrSignatureInvalid
 		}
-		return pubKey, nil
-	})
+		return key.VerificationKey(), nil
+	}, append([]jwt.ParserOption{jwt.WithValidMethods(keys.SupportedAlgorithms())}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
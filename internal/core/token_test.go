@@ -0,0 +1,264 @@
+package core
+
+import (
+	"crypto"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "http://issuer.test"
+
+func newTestKeys(t *testing.T, alg string) *keys.Store {
+	t.Helper()
+	ks, err := keys.LoadStore("", alg, false)
+	if err != nil {
+		t.Fatalf("LoadStore: %v", err)
+	}
+	return ks
+}
+
+// parseAccessToken returns the verified header and claims of token.
+func parseAccessToken(t *testing.T, ks *keys.Store, token string) (map[string]any, jwt.MapClaims) {
+	t.Helper()
+	claims, err := ParseAndValidateToken(token, ks)
+	if err != nil {
+		t.Fatalf("ParseAndValidateToken: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	return parsed.Header, claims
+}
+
+func TestGenerateAccessTokenProfile(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	tests := []struct {
+		name       string
+		req        TokenRequest
+		wantTyp    string
+		wantClaims map[string]any
+		absent     []string
+	}{
+		{
+			name: "plain JWT",
+			req: TokenRequest{
+				Subject:        "alice@example.com",
+				Audience:       []string{"https://api.example.com"},
+				ClientID:       "web",
+				AuthTime:       authTime,
+				ACR:            "1",
+				UserAttributes: map[string]any{"roles": []string{"admin"}},
+			},
+			wantTyp:    "JWT",
+			wantClaims: map[string]any{"client_id": "web", "aud": "https://api.example.com"},
+			absent:     []string{"auth_time", "acr", "roles"},
+		},
+		{
+			name: "RFC 9068",
+			req: TokenRequest{
+				Subject:        "alice@example.com",
+				Audience:       []string{"https://api.example.com"},
+				ClientID:       "web",
+				AuthTime:       authTime,
+				ACR:            "1",
+				UserAttributes: map[string]any{"roles": []any{"admin"}},
+				RFC9068:        true,
+			},
+			wantTyp: "at+jwt",
+			wantClaims: map[string]any{
+				"client_id": "web",
+				"aud":       "https://api.example.com",
+				"auth_time": float64(authTime.Unix()),
+				"acr":       "1",
+			},
+		},
+		{
+			name: "RFC 9068 without a user login",
+			req: TokenRequest{
+				Subject:  "service",
+				Audience: []string{"https://a.example", "https://b.example"},
+				ClientID: "service",
+				ACR:      "1",
+				RFC9068:  true,
+			},
+			wantTyp:    "at+jwt",
+			wantClaims: map[string]any{"client_id": "service"},
+			absent:     []string{"auth_time", "acr"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newTestKeys(t, "RS256")
+			tt.req.ExpiresIn = time.Minute
+			result, err := NewTokenGenerator(ks, testIssuer).Generate(tt.req)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			header, claims := parseAccessToken(t, ks, result.AccessToken)
+			if header["typ"] != tt.wantTyp {
+				t.Errorf("typ = %v, want %v", header["typ"], tt.wantTyp)
+			}
+			for name, want := range tt.wantClaims {
+				if claims[name] != want {
+					t.Errorf("%s = %v, want %v", name, claims[name], want)
+				}
+			}
+			for _, name := range tt.absent {
+				if v, ok := claims[name]; ok {
+					t.Errorf("%s = %v, want it absent", name, v)
+				}
+			}
+			for _, name := range []string{"iss", "sub", "exp", "iat", "jti"} {
+				if _, ok := claims[name]; !ok {
+					t.Errorf("required claim %s missing", name)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateRFC9068UserAttributes(t *testing.T) {
+	ks := newTestKeys(t, "ES256")
+	u := User{Email: "alice@example.com", Role: "admin", Groups: []string{"eng"}}
+	result, err := NewTokenGenerator(ks, testIssuer).Generate(TokenRequest{
+		Subject:        u.Email,
+		Audience:       []string{testIssuer},
+		ClientID:       "web",
+		ExpiresIn:      time.Minute,
+		RFC9068:        true,
+		UserAttributes: AccessTokenUserClaims(u),
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	_, claims := parseAccessToken(t, ks, result.AccessToken)
+	if roles, _ := claims["roles"].([]any); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("roles = %v, want [admin]", claims["roles"])
+	}
+	if groups, _ := claims["groups"].([]any); len(groups) != 1 || groups[0] != "eng" {
+		t.Errorf("groups = %v, want [eng]", claims["groups"])
+	}
+	if _, ok := claims["entitlements"]; ok {
+		t.Error("empty entitlements should be left out")
+	}
+}
+
+func TestParseAndValidateTokenAfterAlgorithmChange(t *testing.T) {
+	dir := t.TempDir()
+	oldKeys, err := keys.LoadStore(dir, "RS256", true)
+	if err != nil {
+		t.Fatalf("LoadStore RS256: %v", err)
+	}
+	issue := func(ks *keys.Store) string {
+		result, err := NewTokenGenerator(ks, testIssuer).Generate(TokenRequest{Subject: "alice", Audience: []string{"web"}, ExpiresIn: time.Minute})
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return result.AccessToken
+	}
+	oldToken := issue(oldKeys)
+
+	// Restarting with another tokens.algorithm retires the RS256 key, which
+	// stays published for the grace period.
+	ks, err := keys.LoadStore(dir, "ES256", true)
+	if err != nil {
+		t.Fatalf("LoadStore ES256: %v", err)
+	}
+	ks.SetGracePeriod(time.Hour)
+	newToken := issue(ks)
+	oldKid := oldKeys.Active().ID
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"token of the old algorithm", oldToken, false},
+		{"token of the new algorithm", newToken, false},
+		{"alg not matching the kid's key", signWithKid(t, ks.Active(), oldKid), true},
+		{"unknown kid", signWithKid(t, ks.Active(), "unknown"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAndValidateToken(tt.token, ks); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// signWithKid signs a token with k but names kid in its header.
+func signWithKid(t *testing.T, k *keys.Key, kid string) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.GetSigningMethod(k.Alg), jwt.MapClaims{
+		"iss": testIssuer,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(k.Private)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
+func TestGenerateIDTokenHashes(t *testing.T) {
+	tests := []struct {
+		name       string
+		alg        string
+		hash       crypto.Hash
+		req        TokenRequest
+		wantAtHash bool
+	}{
+		{"code flow", "RS256", crypto.SHA256, TokenRequest{Nonce: "n-0S6", Code: "code-1"}, true},
+		{"without nonce", "ES384", crypto.SHA384, TokenRequest{Code: "code-2"}, true},
+		{"implicit", "EdDSA", crypto.SHA512, TokenRequest{Nonce: "n-1"}, true},
+		{"ID token only", "HS512", crypto.SHA512, TokenRequest{Nonce: "n-2", IDTokenOnly: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newTestKeys(t, tt.alg)
+			tt.req.Subject = "alice@example.com"
+			tt.req.ClientID = "web"
+			tt.req.ExpiresIn = time.Hour
+			result, err := NewTokenGenerator(ks, testIssuer).Generate(tt.req)
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			_, claims := parseAccessToken(t, ks, result.IDToken)
+
+			leftHalf := func(s string) string {
+				h := tt.hash.New()
+				h.Write([]byte(s))
+				sum := h.Sum(nil)
+				return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+			}
+			want := map[string]string{"nonce": tt.req.Nonce}
+			if tt.wantAtHash {
+				want["at_hash"] = leftHalf(result.AccessToken)
+			}
+			if tt.req.Code != "" {
+				want["c_hash"] = leftHalf(tt.req.Code)
+			}
+			for _, name := range []string{"nonce", "at_hash", "c_hash"} {
+				got, present := claims[name]
+				if want[name] == "" {
+					if present {
+						t.Errorf("%s = %v, want it absent", name, got)
+					}
+					continue
+				}
+				if got != want[name] {
+					t.Errorf("%s = %v, want %s", name, got, want[name])
+				}
+			}
+		})
+	}
+}
@@ -1,40 +1,191 @@
 package core
 
-import "time"
+import (
+	"net/url"
+	"time"
+)
 
 type User struct {
-	Email string `yaml:"email" json:"email"`
-	Role  string `yaml:"role" json:"role"`
-	Dept  string `yaml:"dept" json:"dept"`
+	Email               string   `yaml:"email" json:"email"`
+	Role                string   `yaml:"role" json:"role"`
+	Dept                string   `yaml:"dept" json:"dept"`
+	Name                string   `yaml:"name,omitempty" json:"name,omitempty"`
+	GivenName           string   `yaml:"given_name,omitempty" json:"given_name,omitempty"`
+	FamilyName          string   `yaml:"family_name,omitempty" json:"family_name,omitempty"`
+	PreferredUsername   string   `yaml:"preferred_username,omitempty" json:"preferred_username,omitempty"`
+	Picture             string   `yaml:"picture,omitempty" json:"picture,omitempty"`
+	Locale              string   `yaml:"locale,omitempty" json:"locale,omitempty"`
+	EmailVerified       bool     `yaml:"email_verified,omitempty" json:"email_verified,omitempty"`
+	PhoneNumber         string   `yaml:"phone_number,omitempty" json:"phone_number,omitempty"`
+	PhoneNumberVerified bool     `yaml:"phone_number_verified,omitempty" json:"phone_number_verified,omitempty"`
+	Address             string   `yaml:"address,omitempty" json:"address,omitempty"`
+	Groups              []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Entitlements        []string `yaml:"entitlements,omitempty" json:"entitlements,omitempty"`
+	PasswordHash        string   `yaml:"password_hash,omitempty" json:"-"`
 }
 
 type Client struct {
-	ID           string   `yaml:"id" json:"id"`
-	Secret       string   `yaml:"secret" json:"secret,omitempty"`
-	RedirectURIs []string `yaml:"redirect_uris" json:"redirect_uris"`
+	ID                      string   `yaml:"id" json:"id"`
+	Secret                  string   `yaml:"secret" json:"secret,omitempty"`
+	RedirectURIs            []string `yaml:"redirect_uris" json:"redirect_uris"`
+	SkipConsent             bool     `yaml:"skip_consent,omitempty" json:"skip_consent,omitempty"`
+	PostLogoutRedirectURIs  []string `yaml:"post_logout_redirect_uris,omitempty" json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string   `yaml:"backchannel_logout_uri,omitempty" json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutURI   string   `yaml:"frontchannel_logout_uri,omitempty" json:"frontchannel_logout_uri,omitempty"`
+	GrantTypes              []string `yaml:"grant_types,omitempty" json:"grant_types,omitempty"`
+	ResponseTypes           []string `yaml:"response_types,omitempty" json:"response_types,omitempty"`
+	AllowedScopes           []string `yaml:"allowed_scopes,omitempty" json:"allowed_scopes,omitempty"`
+	TokenEndpointAuthMethod string   `yaml:"token_endpoint_auth_method,omitempty" json:"token_endpoint_auth_method,omitempty"`
+	TokenExchangeAudiences  []string `yaml:"token_exchange_audiences,omitempty" json:"token_exchange_audiences,omitempty"`
+	JWKSURI                 string   `yaml:"jwks_uri,omitempty" json:"jwks_uri,omitempty"`
+	PublicKey               string   `yaml:"public_key,omitempty" json:"public_key,omitempty"`
+	// RegistrationAccessToken lets a dynamically registered client manage
+	// itself at its registration_client_uri (RFC 7592).
+	RegistrationAccessToken string `yaml:"registration_access_token,omitempty" json:"-"`
+	// RequirePushedAuthorizationRequests makes /authorize accept this client's
+	// requests only by request_uri from /oauth2/par.
+	RequirePushedAuthorizationRequests bool `yaml:"require_pushed_authorization_requests,omitempty" json:"require_pushed_authorization_requests,omitempty"`
+	// RequireSignedRequestObject makes /authorize accept this client's
+	// requests only as a signed request object (RFC 9101).
+	RequireSignedRequestObject bool `yaml:"require_signed_request_object,omitempty" json:"require_signed_request_object,omitempty"`
+	// RequestURIs are the http(s) URLs /authorize may fetch this client's
+	// request objects from; no other request_uri is fetched.
+	RequestURIs []string `yaml:"request_uris,omitempty" json:"request_uris,omitempty"`
+	// RequestObjectSigningAlg is the only algorithm accepted for this
+	// client's request objects. Unsigned ones need it set to "none".
+	RequestObjectSigningAlg string `yaml:"request_object_signing_alg,omitempty" json:"request_object_signing_alg,omitempty"`
+	// The tls_client_auth fields name the certificate a client authenticates
+	// with over mTLS (RFC 8705 section 2.1.2); the first one set is matched.
+	TLSClientAuthSubjectDN string `yaml:"tls_client_auth_subject_dn,omitempty" json:"tls_client_auth_subject_dn,omitempty"`
+	TLSClientAuthSANDNS    string `yaml:"tls_client_auth_san_dns,omitempty" json:"tls_client_auth_san_dns,omitempty"`
+	TLSClientAuthSANURI    string `yaml:"tls_client_auth_san_uri,omitempty" json:"tls_client_auth_san_uri,omitempty"`
+	TLSClientAuthSANIP     string `yaml:"tls_client_auth_san_ip,omitempty" json:"tls_client_auth_san_ip,omitempty"`
+	TLSClientAuthSANEmail  string `yaml:"tls_client_auth_san_email,omitempty" json:"tls_client_auth_san_email,omitempty"`
+	// TLSClientCertificateThumbprints are the x5t#S256 thumbprints of the
+	// certificates accepted for self_signed_tls_client_auth.
+	TLSClientCertificateThumbprints []string `yaml:"tls_client_certificate_thumbprints,omitempty" json:"tls_client_certificate_thumbprints,omitempty"`
+	// RFC9068AccessTokens overrides tokens.rfc9068.enabled for this client.
+	RFC9068AccessTokens *bool `yaml:"rfc9068_access_tokens,omitempty" json:"rfc9068_access_tokens,omitempty"`
 }
 
-type AuthCode struct {
-	Code                string
+// Resource is a protected resource (API) that tokens can be aimed at with
+// the resource parameter (RFC 8707). Its identifier becomes the token's aud.
+type Resource struct {
+	Identifier string
+	Name       string
+	// Scopes are the scopes the resource offers; empty means any.
+	Scopes []string
+	// AccessTokenExpiry overrides tokens.access_token_expiry when set.
+	AccessTokenExpiry time.Duration
+}
+
+// AuthRequest is an authorization request waiting for the user to sign in.
+type AuthRequest struct {
+	ID                  string
 	ClientID            string
 	RedirectURI         string
 	Scope               string
 	State               string
-	UserID              string
-	ExpiresAt           time.Time
-	Used                bool
+	Nonce               string
+	LoginHint           string
+	Prompt              string
 	CodeChallenge       string
 	CodeChallengeMethod string
+	ResponseType        string
+	ResponseMode        string
+	Resources           []string
+	// AuthorizationDetails are the rich authorization request objects
+	// (RFC 9396) the client asked for.
+	AuthorizationDetails []map[string]any
+	UserID               string
+	AuthTime             time.Time
+	ExpiresAt            time.Time
 }
 
-type RefreshToken struct {
-	Token     string
-	ClientID  string
+// PushedRequest holds the parameters of a pushed authorization request
+// (RFC 9126) until /authorize redeems its request_uri.
+type PushedRequest struct {
+	RequestURI string
+	ClientID   string
+	Params     url.Values
+	Signed     bool
+	ExpiresAt  time.Time
+}
+
+// Session is a browser's single sign-on session, identified by a cookie.
+type Session struct {
+	ID        string
 	UserID    string
-	Scope     string
+	AuthTime  time.Time
 	ExpiresAt time.Time
-	IssuedAt  time.Time
-	Revoked   bool
+}
+
+type AuthCode struct {
+	Code                 string
+	ClientID             string
+	RedirectURI          string
+	Scope                string
+	State                string
+	UserID               string
+	ExpiresAt            time.Time
+	Used                 bool
+	CodeChallenge        string
+	CodeChallengeMethod  string
+	Nonce                string
+	AuthTime             time.Time
+	Resources            []string
+	AuthorizationDetails []map[string]any
+}
+
+// DeviceStatus is the state of a device authorization request.
+type DeviceStatus string
+
+const (
+	DevicePending  DeviceStatus = "pending"
+	DeviceApproved DeviceStatus = "approved"
+	DeviceDenied   DeviceStatus = "denied"
+)
+
+// DeviceCode is a device authorization request (RFC 8628) waiting for a user
+// to approve it with the user code.
+type DeviceCode struct {
+	DeviceCode string
+	UserCode   string
+	ClientID   string
+	Scope      string
+	Status     DeviceStatus
+	UserID     string
+	AuthTime   time.Time
+	Interval   time.Duration
+	LastPolled time.Time
+	ExpiresAt  time.Time
+}
+
+type RefreshToken struct {
+	Token                string
+	ClientID             string
+	UserID               string
+	Scope                string
+	AuthTime             time.Time
+	ExpiresAt            time.Time
+	IssuedAt             time.Time
+	Revoked              bool
+	Resources            []string
+	AuthorizationDetails []map[string]any
+	// JKT binds the token to a DPoP key; refreshing it then needs a proof
+	// signed with that key.
+	JKT string
+	// X5T binds the token to a client certificate; refreshing it then needs
+	// an mTLS connection with that certificate.
+	X5T string
+}
+
+// Consent records the scopes a user approved for a client.
+type Consent struct {
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	GrantedAt time.Time
 }
 
 type RevokedToken struct {
@@ -52,4 +203,5 @@ type LogEntry struct {
 	UserAgent string
 	Bytes     int
 	Error     string
+	Outbound  bool
 }
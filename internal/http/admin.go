@@ -0,0 +1,73 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"jwtea/internal/keys"
+)
+
+type adminKey struct {
+	Kid       string    `json:"kid"`
+	Alg       string    `json:"alg"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	RetiredAt time.Time `json:"retired_at,omitzero"`
+	ExpiresAt time.Time `json:"published_until,omitzero"`
+}
+
+// AdminKeysHandler handles /admin/keys and /admin/keys/rotate endpoints. They
+// do not exist unless admin.token is set.
+type AdminKeysHandler struct {
+	deps *Dependencies
+}
+
+func NewAdminKeysHandler(deps *Dependencies) *AdminKeysHandler {
+	return &AdminKeysHandler{deps: deps}
+}
+
+func (h *AdminKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.deps.Config.Admin.Token == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !bearerMatches(r, h.deps.Config.Admin.Token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_token", "admin token required")
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/admin/keys" && r.Method == http.MethodGet:
+		h.writeKeys(w)
+	case r.URL.Path == "/admin/keys/rotate" && r.Method == http.MethodPost:
+		if _, err := h.deps.Keys.Rotate(); err != nil {
+			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "key rotation failed: "+err.Error())
+			return
+		}
+		h.writeKeys(w)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AdminKeysHandler) writeKeys(w http.ResponseWriter) {
+	grace := h.deps.Keys.GracePeriod()
+	list := h.deps.Keys.List()
+	out := make([]adminKey, 0, len(list))
+	for _, k := range list {
+		ak := adminKey{
+			Kid:       k.ID,
+			Alg:       k.Alg,
+			Status:    string(k.Status),
+			CreatedAt: k.CreatedAt,
+			RetiredAt: k.RetiredAt,
+		}
+		if k.Status == keys.StatusRetired && !k.RetiredAt.IsZero() {
+			ak.ExpiresAt = k.RetiredAt.Add(grace)
+		}
+		out = append(out, ak)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, map[string]any{"keys": out})
+}
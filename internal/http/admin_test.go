@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminKeysRequiresToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		auth   string
+		method string
+		path   string
+		want   int
+	}{
+		{"no admin token: list", "", "", http.MethodGet, "/admin/keys", http.StatusNotFound},
+		{"no admin token: rotate", "", "", http.MethodPost, "/admin/keys/rotate", http.StatusNotFound},
+		{"no admin token: empty bearer", "", "Bearer ", http.MethodPost, "/admin/keys/rotate", http.StatusNotFound},
+		{"missing credentials", "admin-secret", "", http.MethodPost, "/admin/keys/rotate", http.StatusUnauthorized},
+		{"wrong token", "admin-secret", "Bearer nope", http.MethodPost, "/admin/keys/rotate", http.StatusUnauthorized},
+		{"list", "admin-secret", "Bearer admin-secret", http.MethodGet, "/admin/keys", http.StatusOK},
+		{"rotate", "admin-secret", "Bearer admin-secret", http.MethodPost, "/admin/keys/rotate", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Admin.Token = tt.token
+			before := deps.Keys.Active().ID
+			router := NewRouter(RouterConfig{
+				Store:  deps.Store,
+				Config: deps.Config,
+				Chaos:  deps.Chaos,
+				LogHub: deps.LogHub,
+				Issuer: deps.Issuer,
+				Keys:   deps.Keys,
+			})
+
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			rotated := deps.Keys.Active().ID != before
+			if wantRotated := tt.want == http.StatusOK && tt.path == "/admin/keys/rotate"; rotated != wantRotated {
+				t.Errorf("rotated = %v, want %v", rotated, wantRotated)
+			}
+		})
+	}
+}
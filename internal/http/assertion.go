@@ -0,0 +1,249 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	jwtBearerGrantType  = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// httpClient fetches client jwks_uri key sets and request_uri request objects.
+var httpClient = &http.Client{Timeout: 5 * time.Second}
+
+// authenticateClientAssertion authenticates a client by a signed JWT
+// (RFC 7523): client_secret_jwt when it is HMAC signed with the client
+// secret, private_key_jwt when it is signed with the client's registered key.
+func authenticateClientAssertion(deps *Dependencies, r *http.Request) (core.Client, string, bool) {
+	assertion := r.Form.Get("client_assertion")
+	unverified, _, err := jwt.NewParser().ParseUnverified(assertion, jwt.MapClaims{})
+	if err != nil {
+		return core.Client{}, "", false
+	}
+	iss, _ := unverified.Claims.GetIssuer()
+	if clientID := r.Form.Get("client_id"); clientID != "" && clientID != iss {
+		return core.Client{}, "", false
+	}
+	cl, ok := deps.Store.GetClient(iss)
+	if !ok {
+		return core.Client{}, "", false
+	}
+
+	claims, err := verifyClientJWT(deps, cl, assertion, r.URL.Path)
+	if err != nil {
+		return core.Client{}, "", false
+	}
+	if sub, _ := claims.GetSubject(); sub != cl.ID {
+		return core.Client{}, "", false
+	}
+	method := "private_key_jwt"
+	if alg, err := keys.LookupAlgorithm(unverified.Method.Alg()); err == nil && alg.Symmetric() {
+		method = "client_secret_jwt"
+	}
+	return cl, method, true
+}
+
+// verifyClientJWT checks a JWT issued by cl: its signature against the
+// client's keys, that it is meant for this server, and that its jti has not
+// been seen before.
+func verifyClientJWT(deps *Dependencies, cl core.Client, token, endpoint string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return clientVerificationKey(cl, t)
+	},
+		jwt.WithValidMethods(keys.SupportedAlgorithms()),
+		jwt.WithIssuer(cl.ID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	aud, _ := claims.GetAudience()
+	if !slices.Contains(aud, deps.Issuer) && !slices.Contains(aud, deps.Issuer+endpoint) {
+		return nil, errors.New("assertion audience does not name this server")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, errors.New("assertion has no jti")
+	}
+	exp, _ := claims.GetExpirationTime()
+	if !deps.Store.UseJTI(cl.ID+"\x00"+jti, exp.Time) {
+		return nil, errors.New("assertion jti already used")
+	}
+	return claims, nil
+}
+
+// clientVerificationKey picks the key for a client-signed JWT: the client
+// secret for HMAC algorithms, otherwise the client's public_key or the key
+// from its jwks_uri matching the token's kid.
+func clientVerificationKey(cl core.Client, t *jwt.Token) (any, error) {
+	alg, err := keys.LookupAlgorithm(t.Method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	if alg.Symmetric() {
+		if cl.Secret == "" {
+			return nil, errors.New("client has no secret for client_secret_jwt")
+		}
+		return []byte(cl.Secret), nil
+	}
+
+	if cl.PublicKey != "" {
+		return keys.ParsePublicPEM([]byte(cl.PublicKey))
+	}
+	if cl.JWKSURI == "" {
+		return nil, errors.New("client has no registered public key")
+	}
+
+	jwks, err := fetchClientJWKS(cl.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := t.Header["kid"].(string)
+	for _, j := range jwks {
+		if j.Kty != alg.Kty || (kid != "" && j.Kid != kid) {
+			continue
+		}
+		return keys.PublicKeyFromJWK(j)
+	}
+	return nil, fmt.Errorf("no key in %s matches kid %q", cl.JWKSURI, kid)
+}
+
+func fetchClientJWKS(uri string) ([]keys.JWK, error) {
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", uri, resp.Status)
+	}
+	var set struct {
+		Keys []keys.JWK `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	return set.Keys, nil
+}
+
+// handleJWTBearer implements the JWT bearer authorization grant (RFC 7523
+// section 2.1). The assertion is issued and signed by a client, with sub
+// naming the user (or service) the token is for.
+func (h *TokenHandler) handleJWTBearer(w http.ResponseWriter, r *http.Request) {
+	assertion := r.Form.Get("assertion")
+	if assertion == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "assertion required")
+		return
+	}
+	unverified, _, err := jwt.NewParser().ParseUnverified(assertion, jwt.MapClaims{})
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "assertion is not a JWT")
+		return
+	}
+	iss, _ := unverified.Claims.GetIssuer()
+
+	// Client authentication is optional for this grant; when present it must
+	// be the client that issued the assertion.
+	var cl core.Client
+	_, _, hasBasic := r.BasicAuth()
+	if hasBasic || r.Form.Get("client_id") != "" || r.Form.Get("client_assertion") != "" {
+		authenticated, ok := authenticateClient(h.deps, r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Basic realm=token")
+			WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+			return
+		}
+		if authenticated.ID != iss {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "assertion was not issued by the authenticated client")
+			return
+		}
+		cl = authenticated
+	} else {
+		found, ok := h.deps.Store.GetClient(iss)
+		if !ok {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "assertion issuer is not a registered client")
+			return
+		}
+		cl = found
+	}
+
+	if !requireGrant(w, h.deps.Config, cl, jwtBearerGrantType) {
+		return
+	}
+
+	claims, err := verifyClientJWT(h.deps, cl, assertion, r.URL.Path)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "assertion invalid: "+err.Error())
+		return
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "assertion has no sub")
+		return
+	}
+
+	scope := r.Form.Get("scope")
+	if scope == "" {
+		scope = strings.Join(h.deps.Config.OAuth.DefaultScopes, " ")
+	}
+	if !requireScope(w, h.deps.Config, cl, scope) {
+		return
+	}
+	resources, ok := requireResources(w, r, h.deps.Store, nil, scope)
+	if !ok {
+		return
+	}
+	details, ok := requireAuthorizationDetails(w, r, h.deps.Config)
+	if !ok {
+		return
+	}
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
+	req := core.TokenRequest{
+		Subject:               sub,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
+		Scope:                 scope,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		AuthorizationDetails:  details,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
+		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
+		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
+	}
+
+	applyAccessTokenProfile(h.deps, cl, &req)
+	result, err := gen.Generate(req)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
+		return
+	}
+
+	resp := map[string]any{
+		"access_token": result.AccessToken,
+		"token_type":   accessTokenType(r),
+		"expires_in":   result.ExpiresIn,
+		"scope":        scope,
+	}
+	if len(details) > 0 {
+		resp["authorization_details"] = details
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, resp)
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyClientJWT(t *testing.T) {
+	priv, pub := newECKey(t)
+	otherPriv, _ := newECKey(t)
+	keyClient := core.Client{ID: "key-client", PublicKey: pub}
+	secretClient := core.Client{ID: "secret-client", Secret: "s3cret-s3cret-s3cret-s3cret-s3cret"}
+
+	assertion := func(iss, aud, jti string, exp time.Duration) jwt.MapClaims {
+		c := jwt.MapClaims{"iss": iss, "sub": iss, "aud": aud}
+		if jti != "" {
+			c["jti"] = jti
+		}
+		if exp != 0 {
+			c["exp"] = time.Now().Add(exp).Unix()
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		client  core.Client
+		method  jwt.SigningMethod
+		key     any
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{"private_key_jwt", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", testIssuer, "a1", time.Minute), false},
+		{"endpoint audience", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", testIssuer+"/oauth2/token", "a2", time.Minute), false},
+		{"client_secret_jwt", secretClient, jwt.SigningMethodHS256, []byte(secretClient.Secret), assertion("secret-client", testIssuer, "a3", time.Minute), false},
+		{"other key", keyClient, jwt.SigningMethodES256, otherPriv, assertion("key-client", testIssuer, "a4", time.Minute), true},
+		{"wrong secret", secretClient, jwt.SigningMethodHS256, []byte("not-the-secret-not-the-secret"), assertion("secret-client", testIssuer, "a5", time.Minute), true},
+		{"foreign audience", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", "https://other.example", "a6", time.Minute), true},
+		{"wrong issuer", keyClient, jwt.SigningMethodES256, priv, assertion("someone-else", testIssuer, "a7", time.Minute), true},
+		{"no jti", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", testIssuer, "", time.Minute), true},
+		{"no exp", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", testIssuer, "a8", 0), true},
+		{"expired", keyClient, jwt.SigningMethodES256, priv, assertion("key-client", testIssuer, "a9", -time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			token := signJWT(t, tt.method, tt.key, nil, tt.claims)
+			_, err := verifyClientJWT(deps, tt.client, token, "/oauth2/token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyClientJWT error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientAssertionReplay(t *testing.T) {
+	deps := newTestDeps(t)
+	priv, pub := newECKey(t)
+	deps.Store.AddClient(core.Client{ID: "key-client", PublicKey: pub})
+	h := NewTokenHandler(deps)
+
+	token := signJWT(t, jwt.SigningMethodES256, priv, nil, jwt.MapClaims{
+		"iss": "key-client",
+		"sub": "key-client",
+		"aud": testIssuer,
+		"jti": "only-once",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {clientAssertionType},
+		"client_assertion":      {token},
+	}
+
+	if w := postForm(h, "/oauth2/token", form, nil); w.Code != http.StatusOK {
+		t.Fatalf("first use: status %d, body %s", w.Code, w.Body)
+	}
+	w := postForm(h, "/oauth2/token", form, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("replay: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if got := decodeJSON(t, w)["error"]; got != "invalid_client" {
+		t.Errorf("replay: error %v, want invalid_client", got)
+	}
+}
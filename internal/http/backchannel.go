@@ -0,0 +1,100 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+var backchannelClient = &http.Client{Timeout: 5 * time.Second}
+
+// notifyBackchannelLogout POSTs a signed logout_token for userID to the
+// back-channel logout URI of each client, in the background. Every delivery
+// shows up in the Logs tab as an outbound request.
+func notifyBackchannelLogout(deps *Dependencies, userID string, clientIDs []string) {
+	for _, id := range clientIDs {
+		cl, ok := deps.Store.GetClient(id)
+		if !ok || cl.BackchannelLogoutURI == "" {
+			continue
+		}
+		go deliverLogoutToken(deps, cl, userID)
+	}
+}
+
+func deliverLogoutToken(deps *Dependencies, cl core.Client, userID string) {
+	now := time.Now()
+	entry := core.LogEntry{
+		Time:      now,
+		Method:    http.MethodPost,
+		Path:      cl.BackchannelLogoutURI,
+		UserAgent: "jwtea back-channel logout (" + cl.ID + ")",
+		Outbound:  true,
+	}
+	defer func() {
+		entry.Duration = time.Since(now)
+		deps.LogHub.Append(entry)
+	}()
+
+	jti, err := RandCode(16)
+	if err != nil {
+		entry.Error = fmt.Sprintf("generate jti: %v", err)
+		return
+	}
+	gen := core.NewTokenGenerator(deps.Keys, deps.Issuer)
+	token, err := gen.Sign(jwt.MapClaims{
+		"iss":    deps.Issuer,
+		"sub":    userID,
+		"aud":    cl.ID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(2 * time.Minute).Unix(),
+		"jti":    jti,
+		"events": map[string]any{backchannelLogoutEvent: map[string]any{}},
+	}, "logout+jwt")
+	if err != nil {
+		entry.Error = fmt.Sprintf("sign logout_token: %v", err)
+		return
+	}
+
+	form := url.Values{"logout_token": {token}}
+	resp, err := backchannelClient.Post(cl.BackchannelLogoutURI, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		entry.Error = err.Error()
+		log.Printf("Back-channel logout to %s failed: %v", cl.ID, err)
+		return
+	}
+	_ = resp.Body.Close()
+	entry.Status = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		entry.Error = "client rejected logout_token: " + resp.Status
+	}
+}
+
+// frontchannelLogoutURIs returns the front-channel logout URIs of the given
+// clients, with the issuer appended as the spec's iss parameter.
+func frontchannelLogoutURIs(deps *Dependencies, clientIDs []string) []string {
+	var uris []string
+	for _, id := range clientIDs {
+		cl, ok := deps.Store.GetClient(id)
+		if !ok || cl.FrontchannelLogoutURI == "" {
+			continue
+		}
+		u, err := url.Parse(cl.FrontchannelLogoutURI)
+		if err != nil {
+			continue
+		}
+		q := u.Query()
+		q.Set("iss", deps.Issuer)
+		u.RawQuery = q.Encode()
+		uris = append(uris, u.String())
+	}
+	return uris
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// logoutReceiver starts a back-channel logout endpoint that passes on every
+// logout_token it receives.
+func logoutReceiver(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	tokens := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.PostFormValue("logout_token")
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, tokens
+}
+
+// newLogoutDeps returns dependencies where alice has a session ("sess") and
+// a refresh token ("alice-rt") for the client "rp", whose back-channel
+// logout URI is uri.
+func newLogoutDeps(t *testing.T, uri string) *Dependencies {
+	t.Helper()
+	deps := newTestDeps(t)
+	deps.Store.AddClient(core.Client{
+		ID:                    "rp",
+		Secret:                "secret",
+		BackchannelLogoutURI:  uri,
+		FrontchannelLogoutURI: "https://rp.example.com/logout?tab=1",
+	})
+	deps.Store.TrackUserClient("alice@example.com", "rp")
+	deps.Store.SaveSession(core.Session{ID: "sess", UserID: "alice@example.com", AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+	deps.Store.SaveRefreshToken(core.RefreshToken{Token: "alice-rt", ClientID: "rp", UserID: "alice@example.com", ExpiresAt: time.Now().Add(time.Hour)})
+	return deps
+}
+
+func TestBackchannelLogoutToken(t *testing.T) {
+	uri, tokens := logoutReceiver(t)
+	deps := newLogoutDeps(t, uri)
+
+	r := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "sess"})
+	w := httptest.NewRecorder()
+	NewLogoutHandler(deps).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+
+	var token string
+	select {
+	case token = <-tokens:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no logout_token delivered")
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+	if parsed.Header["typ"] != "logout+jwt" {
+		t.Errorf("typ = %v, want logout+jwt", parsed.Header["typ"])
+	}
+	claims := tokenClaims(t, deps, token)
+	for name, want := range map[string]any{"iss": testIssuer, "sub": "alice@example.com", "aud": "rp"} {
+		if claims[name] != want {
+			t.Errorf("%s = %v, want %v", name, claims[name], want)
+		}
+	}
+	for _, name := range []string{"iat", "exp", "jti"} {
+		if _, ok := claims[name]; !ok {
+			t.Errorf("logout_token has no %s", name)
+		}
+	}
+	if _, ok := claims["nonce"]; ok {
+		t.Error("logout_token carries a nonce")
+	}
+	events, _ := claims["events"].(map[string]any)
+	if _, ok := events[backchannelLogoutEvent]; !ok {
+		t.Errorf("events = %v, want the back-channel logout event", claims["events"])
+	}
+	if clients := deps.Store.UserClients("alice@example.com"); len(clients) != 0 {
+		t.Errorf("clients still tracked after logout: %v", clients)
+	}
+}
+
+func TestFrontchannelLogoutURIs(t *testing.T) {
+	deps := newLogoutDeps(t, "")
+	got := frontchannelLogoutURIs(deps, []string{"rp", "unknown"})
+	want := "https://rp.example.com/logout?iss=" + url.QueryEscape(testIssuer) + "&tab=1"
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("frontchannelLogoutURIs = %v, want [%s]", got, want)
+	}
+}
+
+func TestRevocationIsNotLogout(t *testing.T) {
+	uri, tokens := logoutReceiver(t)
+	deps := newLogoutDeps(t, uri)
+
+	w := postForm(NewRevocationHandler(deps), "/oauth2/revoke", url.Values{
+		"client_id":     {"rp"},
+		"client_secret": {"secret"},
+		"token":         {"alice-rt"},
+	}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d, body %s", w.Code, w.Body)
+	}
+	if _, ok := deps.Store.GetRefreshToken("alice-rt"); ok {
+		t.Fatal("refresh token not revoked")
+	}
+
+	select {
+	case <-tokens:
+		t.Error("revocation sent a logout_token")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if clients := deps.Store.UserClients("alice@example.com"); len(clients) != 1 {
+		t.Errorf("tracked clients = %v, want [rp]", clients)
+	}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/pages"
+)
+
+// continueAuthorization runs after sign-in. It asks for consent when the user
+// has not yet approved the requested scopes for the client and otherwise
+// issues the authorization code.
+func (h *AuthorizeHandler) continueAuthorization(w http.ResponseWriter, r *http.Request, ar core.AuthRequest) {
+	if !h.needsConsent(ar) {
+		if ar.ID != "" {
+			h.deps.Store.DeleteAuthRequest(ar.ID)
+		}
+		h.issueResponse(w, r, ar)
+		return
+	}
+	if hasPrompt(ar.Prompt, "none") {
+		h.errorRedirect(w, r, ar, "consent_required", "the user has not approved the requested scopes")
+		return
+	}
+
+	ar, err := h.saveAuthRequest(ar)
+	if err != nil {
+		h.errorRedirect(w, r, ar, "server_error", "request id generation failed")
+		return
+	}
+	h.renderConsent(w, ar)
+}
+
+func (h *AuthorizeHandler) needsConsent(ar core.AuthRequest) bool {
+	if hasPrompt(ar.Prompt, "consent") {
+		return true
+	}
+	if h.deps.Config.Login.SkipConsent {
+		return false
+	}
+	if cl, ok := h.deps.Store.GetClient(ar.ClientID); ok && cl.SkipConsent {
+		return false
+	}
+	// Authorization details describe a single transaction, such as one
+	// payment, so they are never covered by an earlier consent.
+	if len(ar.AuthorizationDetails) > 0 {
+		return true
+	}
+	if ar.Scope == "" {
+		return false
+	}
+	c, ok := h.deps.Store.GetConsent(ar.UserID, ar.ClientID)
+	return !ok || !IsScopeSubset(ar.Scope, strings.Join(c.Scopes, " "))
+}
+
+func (h *AuthorizeHandler) handleConsent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	ar, ok := h.deps.Store.GetAuthRequest(r.PostForm.Get("request_id"))
+	if !ok || ar.UserID == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "authorization request unknown or expired")
+		return
+	}
+	h.deps.Store.DeleteAuthRequest(ar.ID)
+
+	if r.PostForm.Get("action") != "approve" {
+		h.errorRedirect(w, r, ar, "access_denied", "the user denied the request")
+		return
+	}
+
+	selected := r.PostForm["scope"]
+	var granted []string
+	for _, scope := range strings.Fields(ar.Scope) {
+		if scope == "openid" || slices.Contains(selected, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	h.deps.Store.SaveConsent(core.Consent{
+		UserID:    ar.UserID,
+		ClientID:  ar.ClientID,
+		Scopes:    granted,
+		GrantedAt: time.Now(),
+	})
+	ar.Scope = strings.Join(granted, " ")
+	h.issueResponse(w, r, ar)
+}
+
+func (h *AuthorizeHandler) renderConsent(w http.ResponseWriter, ar core.AuthRequest) {
+	var scopes []pages.ScopeItem
+	for _, scope := range strings.Fields(ar.Scope) {
+		scopes = append(scopes, pages.ScopeItem{
+			Name:        scope,
+			Description: pages.ScopeDescription(scope),
+			Required:    scope == "openid",
+			Checked:     true,
+		})
+	}
+	var details []string
+	for _, d := range ar.AuthorizationDetails {
+		b, _ := json.MarshalIndent(d, "", "  ")
+		details = append(details, string(b))
+	}
+	pages.RenderConsent(w, http.StatusOK, pages.ConsentData{
+		RequestID:            ar.ID,
+		ClientID:             ar.ClientID,
+		UserID:               ar.UserID,
+		Scopes:               scopes,
+		AuthorizationDetails: details,
+	})
+}
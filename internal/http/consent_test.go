@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+func TestConsentDecision(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        url.Values
+		wantError   string
+		wantGranted string
+	}{
+		{"deny", url.Values{"action": {"deny"}}, "access_denied", ""},
+		{"no decision", url.Values{}, "access_denied", ""},
+		{"approve all", url.Values{"action": {"approve"}, "scope": {"profile", "email"}}, "", "openid profile email"},
+		{"approve a subset", url.Values{"action": {"approve"}, "scope": {"email"}}, "", "openid email"},
+		{"openid cannot be unticked", url.Values{"action": {"approve"}}, "", "openid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddClient(core.Client{ID: "web", RedirectURIs: []string{"https://rp.example.com/cb"}})
+			deps.Store.SaveAuthRequest(core.AuthRequest{
+				ID:           "req-1",
+				ClientID:     "web",
+				RedirectURI:  "https://rp.example.com/cb",
+				Scope:        "openid profile email",
+				State:        "xyz",
+				ResponseType: "code",
+				UserID:       "alice@example.com",
+				AuthTime:     time.Now(),
+				ExpiresAt:    time.Now().Add(time.Minute),
+			})
+
+			tt.form.Set("request_id", "req-1")
+			w := postForm(NewAuthorizeHandler(deps), "/authorize/consent", tt.form, nil)
+			if w.Code != http.StatusFound {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			loc, _ := url.Parse(w.Header().Get("Location"))
+			params := loc.Query()
+			if params.Get("state") != "xyz" {
+				t.Errorf("state = %q, want xyz", params.Get("state"))
+			}
+			if got := params.Get("error"); got != tt.wantError {
+				t.Errorf("error = %q, want %q", got, tt.wantError)
+			}
+
+			c, remembered := deps.Store.GetConsent("alice@example.com", "web")
+			if tt.wantError != "" {
+				if remembered || params.Has("code") {
+					t.Errorf("denied request left consent %v and code %q", c.Scopes, params.Get("code"))
+				}
+				return
+			}
+			ac, ok := deps.Store.ConsumeCode(params.Get("code"))
+			if !ok {
+				t.Fatalf("no authorization code in %s", loc)
+			}
+			if ac.Scope != tt.wantGranted {
+				t.Errorf("code scope %q, want %q", ac.Scope, tt.wantGranted)
+			}
+			if !remembered || strings.Join(c.Scopes, " ") != tt.wantGranted {
+				t.Errorf("remembered consent %v, want %q", c.Scopes, tt.wantGranted)
+			}
+			if _, ok := deps.Store.GetAuthRequest("req-1"); ok {
+				t.Error("authorization request still pending")
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package http
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/pages"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// userCodeAlphabet leaves out vowels and look-alike characters, as suggested
+// by RFC 8628 section 6.1.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// DeviceAuthorizationHandler handles /oauth2/device_authorization endpoint
+type DeviceAuthorizationHandler struct {
+	deps *Dependencies
+}
+
+func NewDeviceAuthorizationHandler(deps *Dependencies) *DeviceAuthorizationHandler {
+	return &DeviceAuthorizationHandler{deps: deps}
+}
+
+func (h *DeviceAuthorizationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	cl, ok := authenticateClient(h.deps, r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=token")
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	if !requireGrant(w, h.deps.Config, cl, deviceCodeGrantType) {
+		return
+	}
+
+	scope := r.Form.Get("scope")
+	if scope == "" {
+		scope = strings.Join(h.deps.Config.OAuth.DefaultScopes, " ")
+	}
+	if !requireScope(w, h.deps.Config, cl, scope) {
+		return
+	}
+
+	deviceCode, err := RandCode(32)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "device code generation failed")
+		return
+	}
+	userCode, err := generateUserCode()
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "user code generation failed")
+		return
+	}
+
+	cfg := h.deps.Config.Device
+	h.deps.Store.SaveDeviceCode(core.DeviceCode{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		ClientID:   cl.ID,
+		Scope:      scope,
+		Status:     core.DevicePending,
+		Interval:   cfg.Interval.Duration,
+		ExpiresAt:  time.Now().Add(cfg.CodeExpiry.Duration),
+	})
+
+	verificationURI := h.deps.Issuer + "/device"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(userCode),
+		"expires_in":                int(cfg.CodeExpiry.Seconds()),
+		"interval":                  int(cfg.Interval.Seconds()),
+	})
+}
+
+// generateUserCode returns a code like "BDFH-KLMN".
+func generateUserCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, userCodeAlphabet[int(v)%len(userCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts user codes typed in lower case, with spaces or
+// without the dash.
+func normalizeUserCode(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(s) {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteRune(c)
+		}
+	}
+	code := b.String()
+	if len(code) == 8 {
+		code = code[:4] + "-" + code[4:]
+	}
+	return code
+}
+
+// DeviceVerificationHandler handles /device endpoint
+type DeviceVerificationHandler struct {
+	deps *Dependencies
+}
+
+func NewDeviceVerificationHandler(deps *Dependencies) *DeviceVerificationHandler {
+	return &DeviceVerificationHandler{deps: deps}
+}
+
+func (h *DeviceVerificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		userCode := r.URL.Query().Get("user_code")
+		if userCode == "" {
+			pages.RenderDevice(w, http.StatusOK, pages.DeviceData{})
+			return
+		}
+		h.renderApproval(w, r, normalizeUserCode(userCode), "", "")
+	case http.MethodPost:
+		h.handleDecision(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *DeviceVerificationHandler) handleDecision(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+	userCode := normalizeUserCode(r.PostForm.Get("user_code"))
+	email := r.PostForm.Get("email")
+
+	if r.PostForm.Get("action") == "deny" {
+		if !h.deps.Store.DenyDeviceCode(userCode) {
+			h.renderApproval(w, r, userCode, email, "")
+			return
+		}
+		pages.RenderDevice(w, http.StatusOK, pages.DeviceData{Done: "denied"})
+		return
+	}
+
+	user, ok := h.deps.Store.GetUser(email)
+	if !ok {
+		h.renderApproval(w, r, userCode, email, "Choose a user to continue.")
+		return
+	}
+	if !checkPassword(h.deps.Config, user, r.PostForm.Get("password")) {
+		h.renderApproval(w, r, userCode, email, "Incorrect password.")
+		return
+	}
+	if !h.deps.Store.ApproveDeviceCode(userCode, email) {
+		h.renderApproval(w, r, userCode, email, "")
+		return
+	}
+	pages.RenderDevice(w, http.StatusOK, pages.DeviceData{Done: "approved", Selected: email})
+}
+
+// renderApproval shows the pending request for userCode, or the code entry
+// form again when the code is unknown, expired or already used.
+func (h *DeviceVerificationHandler) renderApproval(w http.ResponseWriter, r *http.Request, userCode, selected, errMsg string) {
+	dc, ok := h.deps.Store.GetDeviceCodeByUserCode(userCode)
+	if !ok {
+		pages.RenderDevice(w, http.StatusBadRequest, pages.DeviceData{
+			UserCode: userCode,
+			Error:    "That code is invalid or has expired.",
+		})
+		return
+	}
+
+	users := sortedUsers(h.deps.Store)
+	if selected == "" {
+		if sess, ok := currentSession(h.deps.Store, r); ok {
+			selected = sess.UserID
+		}
+	}
+	if _, ok := h.deps.Store.GetUser(selected); !ok && len(users) > 0 {
+		selected = users[0].Email
+	}
+
+	status := http.StatusOK
+	if errMsg != "" {
+		status = http.StatusBadRequest
+	}
+	pages.RenderDevice(w, status, pages.DeviceData{
+		UserCode:         dc.UserCode,
+		ClientID:         dc.ClientID,
+		Scope:            dc.Scope,
+		Users:            users,
+		Selected:         selected,
+		PasswordRequired: passwordRequired(h.deps.Config, users),
+		Error:            errMsg,
+	})
+}
+
+func (h *TokenHandler) handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	cl, ok := authenticateClient(h.deps, r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=token")
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	if !requireGrant(w, h.deps.Config, cl, deviceCodeGrantType) {
+		return
+	}
+
+	deviceCode := r.Form.Get("device_code")
+	if deviceCode == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "device_code required")
+		return
+	}
+
+	dc, tooFast, ok := h.deps.Store.PollDeviceCode(deviceCode, time.Now())
+	if !ok || dc.ClientID != cl.ID {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "device code invalid or mismatched")
+		return
+	}
+	if time.Now().After(dc.ExpiresAt) {
+		h.deps.Store.DeleteDeviceCode(deviceCode)
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "expired_token", "device code expired")
+		return
+	}
+
+	switch dc.Status {
+	case core.DeviceDenied:
+		h.deps.Store.DeleteDeviceCode(deviceCode)
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "access_denied", "the user denied the request")
+		return
+	case core.DevicePending:
+		if tooFast {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "slow_down", "polling too fast")
+			return
+		}
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "authorization_pending", "waiting for the user")
+		return
+	}
+	resources, ok := requireResources(w, r, h.deps.Store, nil, dc.Scope)
+	if !ok {
+		return
+	}
+	// The user approved the device code without authorization details, so
+	// none can be added here.
+	if _, ok := requireGrantedAuthorizationDetails(w, r, h.deps.Config, nil); !ok {
+		return
+	}
+	h.deps.Store.DeleteDeviceCode(deviceCode)
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
+	req := core.TokenRequest{
+		Subject:               dc.UserID,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
+		Scope:                 dc.Scope,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		IDTokenExpiresIn:      h.deps.Config.Tokens.IDTokenExpiry.Duration,
+		AuthTime:              dc.AuthTime,
+		UserClaims:            userClaims(h.deps.Store, dc.UserID, dc.Scope),
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
+		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
+		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
+	}
+
+	applyAccessTokenProfile(h.deps, cl, &req)
+	result, err := gen.Generate(req)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
+		return
+	}
+
+	resp := map[string]any{
+		"access_token": result.AccessToken,
+		"token_type":   accessTokenType(r),
+		"expires_in":   result.ExpiresIn,
+		"scope":        dc.Scope,
+	}
+	if HasScope(dc.Scope, "openid") {
+		resp["id_token"] = result.IDToken
+	}
+	h.deps.Store.TrackUserClient(dc.UserID, cl.ID)
+
+	if issuesRefreshToken(h.deps.Config, cl, dc.Scope) {
+		refreshToken, err := GenerateRefreshToken()
+		if err != nil {
+			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "refresh token generation failed")
+			return
+		}
+		h.deps.Store.SaveRefreshToken(core.RefreshToken{
+			Token:     refreshToken,
+			ClientID:  cl.ID,
+			UserID:    dc.UserID,
+			Scope:     dc.Scope,
+			AuthTime:  dc.AuthTime,
+			ExpiresAt: time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
+			IssuedAt:  time.Now(),
+			JKT:       refreshTokenJKT(r, cl),
+			X5T:       refreshTokenX5T(r, cl),
+		})
+		resp["refresh_token"] = refreshToken
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, resp)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+func TestDeviceCodePolling(t *testing.T) {
+	tests := []struct {
+		name         string
+		client       string
+		status       core.DeviceStatus
+		lastPolled   time.Duration // ago; never polled when zero
+		expiresIn    time.Duration
+		wantError    string
+		wantInterval time.Duration
+		wantDeleted  bool
+	}{
+		{"first poll", "tv", core.DevicePending, 0, time.Minute, "authorization_pending", 5 * time.Second, false},
+		{"polled within the interval", "tv", core.DevicePending, time.Second, time.Minute, "slow_down", 10 * time.Second, false},
+		{"polled after the interval", "tv", core.DevicePending, 6 * time.Second, time.Minute, "authorization_pending", 5 * time.Second, false},
+		{"denied", "tv", core.DeviceDenied, 0, time.Minute, "access_denied", 0, true},
+		{"expired", "tv", core.DevicePending, 0, -time.Second, "expired_token", 0, true},
+		{"another client", "other", core.DeviceApproved, 0, time.Minute, "invalid_grant", 0, false},
+		{"approved", "tv", core.DeviceApproved, 0, time.Minute, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddUser(core.User{Email: "alice@example.com"})
+			deps.Store.AddClient(core.Client{ID: "tv", Secret: "secret"})
+			deps.Store.AddClient(core.Client{ID: "other", Secret: "secret"})
+			dc := core.DeviceCode{
+				DeviceCode: "device-1",
+				UserCode:   "ABCD-EFGH",
+				ClientID:   "tv",
+				Scope:      "openid",
+				Status:     tt.status,
+				Interval:   5 * time.Second,
+				ExpiresAt:  time.Now().Add(tt.expiresIn),
+			}
+			if tt.status == core.DeviceApproved {
+				dc.UserID = "alice@example.com"
+				dc.AuthTime = time.Now()
+			}
+			if tt.lastPolled != 0 {
+				dc.LastPolled = time.Now().Add(-tt.lastPolled)
+			}
+			deps.Store.SaveDeviceCode(dc)
+
+			w := postForm(NewTokenHandler(deps), "/oauth2/token", url.Values{
+				"grant_type":    {deviceCodeGrantType},
+				"device_code":   {"device-1"},
+				"client_id":     {tt.client},
+				"client_secret": {"secret"},
+			}, nil)
+			resp := decodeJSON(t, w)
+			if tt.wantError != "" {
+				if w.Code != http.StatusBadRequest || resp["error"] != tt.wantError {
+					t.Fatalf("status %d, error %v, want %s", w.Code, resp["error"], tt.wantError)
+				}
+			} else {
+				if w.Code != http.StatusOK {
+					t.Fatalf("status %d, body %s", w.Code, w.Body)
+				}
+				at, _ := resp["access_token"].(string)
+				if sub := tokenClaims(t, deps, at)["sub"]; sub != "alice@example.com" {
+					t.Errorf("sub = %v, want the approving user", sub)
+				}
+				if _, ok := resp["id_token"]; !ok {
+					t.Error("no id_token for the openid scope")
+				}
+			}
+
+			// A later poll shows whether the device code is gone, and what
+			// interval the client was left with.
+			left, _, found := deps.Store.PollDeviceCode("device-1", time.Now().Add(time.Hour))
+			if found == tt.wantDeleted {
+				t.Errorf("device code left = %v, want %v", found, !tt.wantDeleted)
+			}
+			if tt.wantInterval != 0 && left.Interval != tt.wantInterval {
+				t.Errorf("interval %s, want %s", left.Interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestDeviceCodeDecision(t *testing.T) {
+	deps := newTestDeps(t)
+	deps.Store.SaveDeviceCode(core.DeviceCode{DeviceCode: "d1", UserCode: "AAAA-BBBB", Status: core.DevicePending, ExpiresAt: time.Now().Add(time.Minute)})
+	deps.Store.SaveDeviceCode(core.DeviceCode{DeviceCode: "d2", UserCode: "CCCC-DDDD", Status: core.DevicePending, ExpiresAt: time.Now().Add(time.Minute)})
+	deps.Store.SaveDeviceCode(core.DeviceCode{DeviceCode: "d3", UserCode: "EEEE-FFFF", Status: core.DevicePending, ExpiresAt: time.Now().Add(-time.Second)})
+
+	if !deps.Store.ApproveDeviceCode("AAAA-BBBB", "alice@example.com") {
+		t.Fatal("ApproveDeviceCode failed")
+	}
+	if deps.Store.DenyDeviceCode("AAAA-BBBB") {
+		t.Error("an approved device code was denied")
+	}
+	if !deps.Store.DenyDeviceCode("CCCC-DDDD") {
+		t.Fatal("DenyDeviceCode failed")
+	}
+	if deps.Store.ApproveDeviceCode("EEEE-FFFF", "alice@example.com") {
+		t.Error("an expired device code was approved")
+	}
+	if pending := deps.Store.ListPendingDeviceCodes(); len(pending) != 0 {
+		t.Errorf("pending device codes %v, want none", pending)
+	}
+
+	approved, _, _ := deps.Store.PollDeviceCode("d1", time.Now())
+	if approved.Status != core.DeviceApproved || approved.UserID != "alice@example.com" || approved.AuthTime.IsZero() {
+		t.Errorf("approved device code %+v", approved)
+	}
+	denied, _, _ := deps.Store.PollDeviceCode("d2", time.Now())
+	if denied.Status != core.DeviceDenied {
+		t.Errorf("denied device code status %s", denied.Status)
+	}
+}
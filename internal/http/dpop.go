@@ -0,0 +1,180 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// dpopNonceLifetime is how long a nonce from a DPoP-Nonce header is accepted.
+const dpopNonceLifetime = 5 * time.Minute
+
+// errUseDPoPNonce means a proof lacks a current server nonce; the client
+// should retry with the one in the DPoP-Nonce response header.
+var errUseDPoPNonce = errors.New("DPoP proof requires a server nonce")
+
+type dpopContextKey struct{}
+
+// dpopAlgorithms are the algorithms accepted for DPoP proofs. The proof key
+// travels in the proof, so only asymmetric algorithms make sense.
+func dpopAlgorithms() []string {
+	var algs []string
+	for _, name := range keys.SupportedAlgorithms() {
+		if alg, err := keys.LookupAlgorithm(name); err == nil && !alg.Symmetric() {
+			algs = append(algs, name)
+		}
+	}
+	return algs
+}
+
+// verifyDPoPProof validates the DPoP proof header of r (RFC 9449 section
+// 4.3) and returns the thumbprint of its key. accessToken is the token sent
+// with the proof to a resource, which the proof's ath must hash; it is empty
+// at the token endpoint.
+func verifyDPoPProof(deps *Dependencies, r *http.Request, accessToken string) (string, error) {
+	proofs := r.Header.Values("DPoP")
+	if len(proofs) != 1 {
+		return "", errors.New("exactly one DPoP header required")
+	}
+
+	var jwk keys.JWK
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(proofs[0], claims, func(t *jwt.Token) (any, error) {
+		if typ, _ := t.Header["typ"].(string); typ != "dpop+jwt" {
+			return nil, errors.New("proof typ must be dpop+jwt")
+		}
+		raw, err := json.Marshal(t.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &jwk); err != nil || jwk.Kty == "" {
+			return nil, errors.New("proof has no jwk header")
+		}
+		if jwk.D != "" || jwk.K != "" {
+			return nil, errors.New("proof jwk must be a public key")
+		}
+		return keys.PublicKeyFromJWK(jwk)
+	}, jwt.WithValidMethods(dpopAlgorithms()))
+	if err != nil {
+		return "", err
+	}
+
+	lifetime := deps.Config.DPoP.ProofLifetime.Duration
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return "", errors.New("proof has no iat")
+	}
+	if age := time.Since(iat.Time); age > lifetime || age < -lifetime {
+		return "", errors.New("proof iat is outside the accepted window")
+	}
+	if htm, _ := claims["htm"].(string); htm != r.Method {
+		return "", fmt.Errorf("proof htm does not match %s", r.Method)
+	}
+	if htu, _ := claims["htu"].(string); !sameHTU(htu, endpointURL(deps, r)) {
+		return "", errors.New("proof htu does not match this endpoint")
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", errors.New("proof ath does not match the access token")
+		}
+	}
+	if deps.Config.DPoP.RequireNonce {
+		if nonce, _ := claims["nonce"].(string); !deps.Store.ValidDPoPNonce(nonce) {
+			return "", errUseDPoPNonce
+		}
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", errors.New("proof has no jti")
+	}
+	if !deps.Store.UseJTI("dpop\x00"+jti, iat.Add(lifetime)) {
+		return "", errors.New("proof jti already used")
+	}
+	return keys.Thumbprint(jwk)
+}
+
+// sameHTU compares a proof's htu with an endpoint URL, ignoring query and
+// fragment and the case of scheme and host.
+func sameHTU(htu, endpoint string) bool {
+	u, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	e, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, e.Scheme) && strings.EqualFold(u.Host, e.Host) && u.Path == e.Path
+}
+
+// issueDPoPNonce hands the client a fresh nonce in the DPoP-Nonce header.
+func issueDPoPNonce(deps *Dependencies, w http.ResponseWriter) {
+	nonce, err := RandCode(16)
+	if err != nil {
+		return
+	}
+	deps.Store.SaveDPoPNonce(nonce, time.Now().Add(dpopNonceLifetime))
+	w.Header().Set("DPoP-Nonce", nonce)
+}
+
+// checkDPoP validates the DPoP proof sent to the token endpoint, if any, and
+// returns r carrying the proof key's thumbprint. It writes the error response
+// itself when the proof is unacceptable.
+func (h *TokenHandler) checkDPoP(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if len(r.Header.Values("DPoP")) == 0 {
+		return r, true
+	}
+	jkt, err := verifyDPoPProof(h.deps, r, "")
+	if errors.Is(err, errUseDPoPNonce) {
+		issueDPoPNonce(h.deps, w)
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "use_dpop_nonce", "use the nonce from the DPoP-Nonce header")
+		return nil, false
+	}
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_dpop_proof", err.Error())
+		return nil, false
+	}
+	if h.deps.Config.DPoP.RequireNonce {
+		issueDPoPNonce(h.deps, w)
+	}
+	return r.WithContext(context.WithValue(r.Context(), dpopContextKey{}, jkt)), true
+}
+
+// dpopJKT is the thumbprint of the DPoP key that access tokens issued for r
+// are bound to, or "" for bearer tokens.
+func dpopJKT(r *http.Request) string {
+	jkt, _ := r.Context().Value(dpopContextKey{}).(string)
+	return jkt
+}
+
+// accessTokenType is the token_type of access tokens issued for r.
+func accessTokenType(r *http.Request) string {
+	if dpopJKT(r) != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
+
+// refreshTokenJKT is the DPoP key a new refresh token for cl is bound to.
+// RFC 9449 binds the refresh tokens of public clients, which have no other
+// credential protecting them.
+func refreshTokenJKT(r *http.Request, cl core.Client) string {
+	if cl.Secret != "" || cl.PublicKey != "" || cl.JWKSURI != "" {
+		return ""
+	}
+	return dpopJKT(r)
+}
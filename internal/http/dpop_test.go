@@ -0,0 +1,232 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// dpopProof signs a DPoP proof for htm and htu with k, applying edit to its
+// header and claims first.
+func dpopProof(t *testing.T, k *keys.Key, htm, htu string, edit func(header map[string]any, claims jwt.MapClaims)) string {
+	t.Helper()
+	header := map[string]any{"typ": "dpop+jwt", "jwk": k.JWK()}
+	claims := jwt.MapClaims{
+		"htm": htm,
+		"htu": htu,
+		"iat": time.Now().Unix(),
+		"jti": rand16(t),
+	}
+	if edit != nil {
+		edit(header, claims)
+	}
+	return signJWT(t, jwt.GetSigningMethod(k.Alg), k.Private, header, claims)
+}
+
+func rand16(t *testing.T) string {
+	t.Helper()
+	s, err := RandCode(16)
+	if err != nil {
+		t.Fatalf("RandCode: %v", err)
+	}
+	return s
+}
+
+func newDPoPKey(t *testing.T) *keys.Key {
+	t.Helper()
+	k, err := keys.Generate("ES256")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return k
+}
+
+func athOf(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestVerifyDPoPProof(t *testing.T) {
+	k := newDPoPKey(t)
+	other := newDPoPKey(t)
+	privateJWK, err := k.PrivateJWK()
+	if err != nil {
+		t.Fatalf("PrivateJWK: %v", err)
+	}
+	hmacKey, err := keys.Generate("HS256")
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	htu := testIssuer + "/oauth2/token"
+
+	tests := []struct {
+		name        string
+		proof       func() string
+		accessToken string
+		wantErr     bool
+	}{
+		{"valid", func() string { return dpopProof(t, k, "POST", htu, nil) }, "", false},
+		{"htu query ignored", func() string { return dpopProof(t, k, "POST", htu+"?x=1", nil) }, "", false},
+		{"wrong typ", func() string {
+			return dpopProof(t, k, "POST", htu, func(h map[string]any, _ jwt.MapClaims) { h["typ"] = "JWT" })
+		}, "", true},
+		{"no jwk", func() string {
+			return dpopProof(t, k, "POST", htu, func(h map[string]any, _ jwt.MapClaims) { delete(h, "jwk") })
+		}, "", true},
+		{"private jwk", func() string {
+			return dpopProof(t, k, "POST", htu, func(h map[string]any, _ jwt.MapClaims) { h["jwk"] = privateJWK })
+		}, "", true},
+		{"jwk of another key", func() string {
+			return dpopProof(t, k, "POST", htu, func(h map[string]any, _ jwt.MapClaims) { h["jwk"] = other.JWK() })
+		}, "", true},
+		{"symmetric alg", func() string {
+			header := map[string]any{"typ": "dpop+jwt", "jwk": k.JWK()}
+			return signJWT(t, jwt.SigningMethodHS256, hmacKey.Private, header, jwt.MapClaims{
+				"htm": "POST", "htu": htu, "iat": time.Now().Unix(), "jti": rand16(t),
+			})
+		}, "", true},
+		{"wrong htm", func() string { return dpopProof(t, k, "GET", htu, nil) }, "", true},
+		{"wrong htu", func() string { return dpopProof(t, k, "POST", testIssuer+"/userinfo", nil) }, "", true},
+		{"stale iat", func() string {
+			return dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) { c["iat"] = time.Now().Add(-time.Hour).Unix() })
+		}, "", true},
+		{"future iat", func() string {
+			return dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() })
+		}, "", true},
+		{"no jti", func() string {
+			return dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) { delete(c, "jti") })
+		}, "", true},
+		{"ath matches", func() string {
+			return dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) { c["ath"] = athOf("the-token") })
+		}, "the-token", false},
+		{"ath missing", func() string { return dpopProof(t, k, "POST", htu, nil) }, "the-token", true},
+		{"ath of another token", func() string {
+			return dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) { c["ath"] = athOf("other-token") })
+		}, "the-token", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			r := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+			r.Header.Set("DPoP", tt.proof())
+			jkt, err := verifyDPoPProof(deps, r, tt.accessToken)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyDPoPProof error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want, _ := keys.Thumbprint(k.JWK())
+			if jkt != want {
+				t.Errorf("jkt = %q, want %q", jkt, want)
+			}
+		})
+	}
+}
+
+func TestVerifyDPoPProofReplay(t *testing.T) {
+	deps := newTestDeps(t)
+	proof := dpopProof(t, newDPoPKey(t), "POST", testIssuer+"/oauth2/token", nil)
+	for i, wantErr := range []bool{false, true} {
+		r := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+		r.Header.Set("DPoP", proof)
+		if _, err := verifyDPoPProof(deps, r, ""); (err != nil) != wantErr {
+			t.Fatalf("use %d: error = %v, want error %v", i+1, err, wantErr)
+		}
+	}
+}
+
+func TestVerifyDPoPProofNonce(t *testing.T) {
+	deps := newTestDeps(t)
+	deps.Config.DPoP.RequireNonce = true
+	deps.Store.SaveDPoPNonce("server-nonce", time.Now().Add(time.Minute))
+	k := newDPoPKey(t)
+	htu := testIssuer + "/oauth2/token"
+
+	tests := []struct {
+		name  string
+		nonce string
+		want  error
+	}{
+		{"no nonce", "", errUseDPoPNonce},
+		{"unknown nonce", "made-up", errUseDPoPNonce},
+		{"server nonce", "server-nonce", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+			r.Header.Set("DPoP", dpopProof(t, k, "POST", htu, func(_ map[string]any, c jwt.MapClaims) {
+				if tt.nonce != "" {
+					c["nonce"] = tt.nonce
+				}
+			}))
+			if _, err := verifyDPoPProof(deps, r, ""); !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDPoPBoundAccessToken(t *testing.T) {
+	deps := newTestDeps(t)
+	deps.Store.AddClient(core.Client{ID: "dpop-client", Secret: "secret"})
+	deps.Store.AddUser(core.User{Email: "dpop-client"})
+	k := newDPoPKey(t)
+	jkt, _ := keys.Thumbprint(k.JWK())
+
+	w := postForm(NewTokenHandler(deps), "/oauth2/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"dpop-client"},
+		"client_secret": {"secret"},
+		"scope":         {"openid"},
+	}, http.Header{"Dpop": {dpopProof(t, k, "POST", testIssuer+"/oauth2/token", nil)}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("token: status %d, body %s", w.Code, w.Body)
+	}
+	resp := decodeJSON(t, w)
+	if resp["token_type"] != "DPoP" {
+		t.Errorf("token_type = %v, want DPoP", resp["token_type"])
+	}
+	at, _ := resp["access_token"].(string)
+	cnf, _ := tokenClaims(t, deps, at)["cnf"].(map[string]any)
+	if cnf["jkt"] != jkt {
+		t.Fatalf("cnf.jkt = %v, want %s", cnf["jkt"], jkt)
+	}
+
+	tests := []struct {
+		name   string
+		scheme string
+		key    *keys.Key
+		want   int
+	}{
+		{"proof from the bound key", "DPoP", k, http.StatusOK},
+		{"proof from another key", "DPoP", newDPoPKey(t), http.StatusUnauthorized},
+		{"bearer scheme", "Bearer", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			r.Header.Set("Authorization", tt.scheme+" "+at)
+			if tt.key != nil {
+				r.Header.Set("DPoP", dpopProof(t, tt.key, "GET", testIssuer+"/userinfo", func(_ map[string]any, c jwt.MapClaims) {
+					c["ath"] = athOf(at)
+				}))
+			}
+			w := httptest.NewRecorder()
+			NewUserInfoHandler(deps).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
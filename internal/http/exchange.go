@@ -0,0 +1,209 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+const (
+	tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	tokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+	tokenTypeIDToken     = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// exchangeableTokenTypes are the subject and actor token types jwtea can
+// validate: every token it issues is a JWT signed with its own keys.
+var exchangeableTokenTypes = []string{tokenTypeAccessToken, tokenTypeJWT, tokenTypeIDToken}
+
+// handleTokenExchange implements RFC 8693. Without an actor_token the new
+// token impersonates the subject; with one, the actor is recorded in an act
+// claim (delegation), nesting any act chain the subject token already had.
+func (h *TokenHandler) handleTokenExchange(w http.ResponseWriter, r *http.Request) {
+	cl, ok := authenticateClient(h.deps, r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=token")
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	if !requireGrant(w, h.deps.Config, cl, tokenExchangeGrantType) {
+		return
+	}
+
+	subject, ok := h.exchangeToken(w, r, r.Form.Get("subject_token"), r.Form.Get("subject_token_type"), "subject_token")
+	if !ok {
+		return
+	}
+	if !subjectTokenFor(subject, cl) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "subject_token was not issued to this client")
+		return
+	}
+	sub, _ := subject["sub"].(string)
+
+	var actor map[string]any
+	if actorToken := r.Form.Get("actor_token"); actorToken != "" {
+		actorClaims, ok := h.exchangeToken(w, r, actorToken, r.Form.Get("actor_token_type"), "actor_token")
+		if !ok {
+			return
+		}
+		actorSub, _ := actorClaims["sub"].(string)
+		if mayAct, ok := subject["may_act"].(map[string]any); ok && mayAct["sub"] != actorSub {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "actor is not allowed to act for the subject")
+			return
+		}
+		actor = map[string]any{"sub": actorSub}
+		if prior, ok := subject["act"]; ok {
+			actor["act"] = prior
+		}
+	} else if r.Form.Get("actor_token_type") != "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "actor_token_type given without actor_token")
+		return
+	}
+
+	requestedType := r.Form.Get("requested_token_type")
+	if requestedType == "" {
+		requestedType = tokenTypeAccessToken
+	}
+	if requestedType != tokenTypeAccessToken && requestedType != tokenTypeJWT {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "unsupported requested_token_type")
+		return
+	}
+
+	// Resources here need not be registered, as token_exchange_audiences
+	// already limits them; registered ones bring their scopes and lifetime.
+	audiences := r.Form["audience"]
+	var resources []core.Resource
+	for _, resource := range r.Form["resource"] {
+		if u, err := url.Parse(resource); err != nil || !u.IsAbs() || u.Fragment != "" {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_target", "resource must be an absolute URI")
+			return
+		}
+		if registered, ok := h.deps.Store.GetResource(resource); ok {
+			resources = append(resources, registered)
+		}
+		audiences = append(audiences, resource)
+	}
+	if len(audiences) == 0 {
+		audiences = []string{cl.ID}
+	}
+	for _, aud := range audiences {
+		if !exchangeAudienceAllowed(cl, aud) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_target", "client may not exchange tokens for audience "+aud)
+			return
+		}
+	}
+
+	subjectScope, _ := subject["scope"].(string)
+	scope := subjectScope
+	if requested := r.Form.Get("scope"); requested != "" {
+		if !IsScopeSubset(requested, subjectScope) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_scope", "requested scope exceeds the subject token's scope")
+			return
+		}
+		scope = requested
+	}
+	if !requireScope(w, h.deps.Config, cl, scope) {
+		return
+	}
+	if len(resources) > 0 && !resourcesOfferScope(resources, scope) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_scope", "requested scope is not offered by the resource")
+		return
+	}
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
+	req := core.TokenRequest{
+		Subject:               sub,
+		Audience:              audiences,
+		ClientID:              cl.ID,
+		Scope:                 scope,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		Actor:                 actor,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
+		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
+		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
+	}
+
+	applyAccessTokenProfile(h.deps, cl, &req)
+	result, err := gen.Generate(req)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
+		return
+	}
+
+	resp := map[string]any{
+		"access_token":      result.AccessToken,
+		"issued_token_type": requestedType,
+		"token_type":        accessTokenType(r),
+		"expires_in":        result.ExpiresIn,
+	}
+	if scope != "" {
+		resp["scope"] = scope
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, resp)
+}
+
+// exchangeToken validates a subject or actor token and returns its claims,
+// writing the error response itself when the token is unacceptable. A
+// sender-constrained token needs a DPoP proof or client certificate for its
+// key on r, which the new token is then bound to as well.
+func (h *TokenHandler) exchangeToken(w http.ResponseWriter, r *http.Request, token, tokenType, param string) (jwt.MapClaims, bool) {
+	if token == "" || tokenType == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", param+" and "+param+"_type required")
+		return nil, false
+	}
+	if !slices.Contains(exchangeableTokenTypes, tokenType) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "unsupported "+param+"_type")
+		return nil, false
+	}
+	claims, err := core.ParseAndValidateToken(token, h.deps.Keys)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", param+" invalid or expired")
+		return nil, false
+	}
+	if jti, ok := claims["jti"].(string); ok && h.deps.Store.IsAccessTokenRevoked(jti) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", param+" has been revoked")
+		return nil, false
+	}
+	cnf, _ := claims["cnf"].(map[string]any)
+	if jkt, _ := cnf["jkt"].(string); jkt != "" && jkt != dpopJKT(r) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", param+" is DPoP-bound and needs a proof from its key")
+		return nil, false
+	}
+	if x5t, _ := cnf["x5t#S256"].(string); x5t != "" && x5t != certThumbprint(r) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", param+" is certificate-bound and needs its client certificate")
+		return nil, false
+	}
+	return claims, true
+}
+
+// subjectTokenFor reports whether a subject token was issued to or for cl,
+// through its aud, client_id or azp, or names cl in its may_act claim.
+func subjectTokenFor(claims jwt.MapClaims, cl core.Client) bool {
+	if aud, _ := claims.GetAudience(); slices.Contains(aud, cl.ID) {
+		return true
+	}
+	if claims["client_id"] == cl.ID || claims["azp"] == cl.ID {
+		return true
+	}
+	mayAct, _ := claims["may_act"].(map[string]any)
+	return mayAct["sub"] == cl.ID || mayAct["client_id"] == cl.ID
+}
+
+// exchangeAudienceAllowed reports whether cl may exchange tokens into aud:
+// its own client ID, or one listed in token_exchange_audiences ("*" allows
+// any).
+func exchangeAudienceAllowed(cl core.Client, aud string) bool {
+	if aud == cl.ID {
+		return true
+	}
+	return slices.Contains(cl.TokenExchangeAudiences, aud) || slices.Contains(cl.TokenExchangeAudiences, "*")
+}
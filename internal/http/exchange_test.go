@@ -0,0 +1,225 @@
+package http
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+	"jwtea/internal/pki"
+)
+
+// newExchangeDeps returns dependencies with token exchange enabled for the
+// clients "owner" and "other" (secret "secret").
+func newExchangeDeps(t *testing.T) *Dependencies {
+	t.Helper()
+	deps := newTestDeps(t)
+	deps.Config.OAuth.AllowedGrantTypes = append(deps.Config.OAuth.AllowedGrantTypes, tokenExchangeGrantType)
+	for _, id := range []string{"owner", "other"} {
+		deps.Store.AddClient(core.Client{
+			ID:         id,
+			Secret:     "secret",
+			GrantTypes: []string{"client_credentials", tokenExchangeGrantType},
+		})
+	}
+	return deps
+}
+
+// issueToken signs an access token for req with the keys of deps.
+func issueToken(t *testing.T, deps *Dependencies, req core.TokenRequest) string {
+	t.Helper()
+	if req.ExpiresIn == 0 {
+		req.ExpiresIn = time.Hour
+	}
+	result, err := core.NewTokenGenerator(deps.Keys, deps.Issuer).Generate(req)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return result.AccessToken
+}
+
+// exchange posts a token exchange request from clientID.
+func exchange(deps *Dependencies, clientID string, form url.Values) (int, map[string]any) {
+	form.Set("grant_type", tokenExchangeGrantType)
+	form.Set("client_id", clientID)
+	form.Set("client_secret", "secret")
+	w := postForm(NewTokenHandler(deps), "/oauth2/token", form, nil)
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+func TestTokenExchangeSubjectClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject core.TokenRequest
+		want    int
+	}{
+		{"issued to the client", core.TokenRequest{Subject: "alice", Audience: []string{"owner"}, ClientID: "owner"}, http.StatusOK},
+		{"aimed at the client", core.TokenRequest{Subject: "alice", Audience: []string{"owner"}}, http.StatusOK},
+		{"issued to the client for a resource", core.TokenRequest{Subject: "alice", Audience: []string{"https://api.example.com"}, ClientID: "owner"}, http.StatusOK},
+		{"authorized party", core.TokenRequest{Subject: "alice", Audience: []string{"https://api.example.com"}, CustomClaims: map[string]any{"azp": "owner"}}, http.StatusOK},
+		{"may_act names the client", core.TokenRequest{Subject: "alice", Audience: []string{"other"}, ClientID: "other", CustomClaims: map[string]any{"may_act": map[string]any{"sub": "owner"}}}, http.StatusOK},
+		{"issued to another client", core.TokenRequest{Subject: "alice", Audience: []string{"other"}, ClientID: "other"}, http.StatusBadRequest},
+		{"may_act names someone else", core.TokenRequest{Subject: "alice", Audience: []string{"other"}, ClientID: "other", CustomClaims: map[string]any{"may_act": map[string]any{"sub": "mallory"}}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newExchangeDeps(t)
+			status, resp := exchange(deps, "owner", url.Values{
+				"subject_token":      {issueToken(t, deps, tt.subject)},
+				"subject_token_type": {tokenTypeAccessToken},
+			})
+			if status != tt.want {
+				t.Fatalf("status %d, want %d: %v", status, tt.want, resp)
+			}
+			if status == http.StatusOK {
+				if claims := tokenClaims(t, deps, resp["access_token"].(string)); claims["sub"] != "alice" {
+					t.Errorf("sub = %v, want alice", claims["sub"])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenExchangeRequiresOptIn(t *testing.T) {
+	deps := newExchangeDeps(t)
+	deps.Store.AddClient(core.Client{ID: "plain", Secret: "secret"})
+	status, resp := exchange(deps, "plain", url.Values{
+		"subject_token":      {issueToken(t, deps, core.TokenRequest{Subject: "alice", Audience: []string{"plain"}})},
+		"subject_token_type": {tokenTypeAccessToken},
+	})
+	if status != http.StatusBadRequest || resp["error"] != "unauthorized_client" {
+		t.Errorf("status %d, response %v; want unauthorized_client", status, resp)
+	}
+}
+
+func TestTokenExchangeSenderConstrained(t *testing.T) {
+	ca := newTestCA(t)
+	cert := clientCert(t, ca, "owner")
+	k := newDPoPKey(t)
+	jkt, _ := keys.Thumbprint(k.JWK())
+
+	tests := []struct {
+		name    string
+		subject core.TokenRequest
+		actor   *core.TokenRequest
+		proof   *keys.Key
+		cert    *x509.Certificate
+		want    int
+	}{
+		{"DPoP-bound, proof from its key", core.TokenRequest{JKT: jkt}, nil, k, nil, http.StatusOK},
+		{"DPoP-bound, no proof", core.TokenRequest{JKT: jkt}, nil, nil, nil, http.StatusBadRequest},
+		{"DPoP-bound, proof from another key", core.TokenRequest{JKT: jkt}, nil, newDPoPKey(t), nil, http.StatusBadRequest},
+		{"DPoP-bound actor, no proof", core.TokenRequest{}, &core.TokenRequest{Subject: "svc", JKT: jkt}, nil, nil, http.StatusBadRequest},
+		{"certificate-bound, its certificate", core.TokenRequest{X5T: pki.Thumbprint(cert)}, nil, nil, cert, http.StatusOK},
+		{"certificate-bound, no certificate", core.TokenRequest{X5T: pki.Thumbprint(cert)}, nil, nil, nil, http.StatusBadRequest},
+		{"certificate-bound, another certificate", core.TokenRequest{X5T: pki.Thumbprint(cert)}, nil, nil, clientCert(t, ca, "owner"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newExchangeDeps(t)
+			deps.CA = ca
+			tt.subject.Subject = "alice"
+			tt.subject.Audience = []string{"owner"}
+			form := url.Values{
+				"grant_type":         {tokenExchangeGrantType},
+				"client_id":          {"owner"},
+				"client_secret":      {"secret"},
+				"subject_token":      {issueToken(t, deps, tt.subject)},
+				"subject_token_type": {tokenTypeAccessToken},
+			}
+			if tt.actor != nil {
+				form.Set("actor_token", issueToken(t, deps, *tt.actor))
+				form.Set("actor_token_type", tokenTypeAccessToken)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.proof != nil {
+				r.Header.Set("DPoP", dpopProof(t, tt.proof, "POST", testIssuer+"/oauth2/token", nil))
+			}
+			w := httptest.NewRecorder()
+			NewTokenHandler(deps).ServeHTTP(w, withCert(r, tt.cert))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			// The new token keeps the subject token's binding.
+			cnf, _ := tokenClaims(t, deps, decodeJSON(t, w)["access_token"].(string))["cnf"].(map[string]any)
+			if tt.subject.JKT != "" && cnf["jkt"] != tt.subject.JKT {
+				t.Errorf("cnf.jkt = %v, want %s", cnf["jkt"], tt.subject.JKT)
+			}
+			if tt.subject.X5T != "" && cnf["x5t#S256"] != tt.subject.X5T {
+				t.Errorf("cnf.x5t#S256 = %v, want %s", cnf["x5t#S256"], tt.subject.X5T)
+			}
+		})
+	}
+}
+
+func TestTokenExchangeActor(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject map[string]any // extra claims of the subject token
+		actor   string         // sub of the actor token; none when empty
+		form    url.Values
+		want    int
+		wantAct any
+	}{
+		{"impersonation", nil, "", nil, http.StatusOK, nil},
+		{"delegation", nil, "svc-a", nil, http.StatusOK, map[string]any{"sub": "svc-a"}},
+		{"delegation chain", map[string]any{"act": map[string]any{"sub": "svc-0"}}, "svc-a", nil, http.StatusOK,
+			map[string]any{"sub": "svc-a", "act": map[string]any{"sub": "svc-0"}}},
+		{"may_act names the actor", map[string]any{"may_act": map[string]any{"sub": "svc-a"}}, "svc-a", nil, http.StatusOK, map[string]any{"sub": "svc-a"}},
+		{"may_act names another actor", map[string]any{"may_act": map[string]any{"sub": "svc-b"}}, "svc-a", nil, http.StatusBadRequest, nil},
+		{"actor_token_type without actor_token", nil, "", url.Values{"actor_token_type": {tokenTypeAccessToken}}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newExchangeDeps(t)
+			form := url.Values{
+				"subject_token": {issueToken(t, deps, core.TokenRequest{
+					Subject:      "alice",
+					Audience:     []string{"owner"},
+					ClientID:     "owner",
+					CustomClaims: tt.subject,
+				})},
+				"subject_token_type": {tokenTypeAccessToken},
+			}
+			if tt.actor != "" {
+				form.Set("actor_token", issueToken(t, deps, core.TokenRequest{Subject: tt.actor, Audience: []string{"owner"}}))
+				form.Set("actor_token_type", tokenTypeAccessToken)
+			}
+			for k, v := range tt.form {
+				form[k] = v
+			}
+
+			status, resp := exchange(deps, "owner", form)
+			if status != tt.want {
+				t.Fatalf("status %d, want %d: %v", status, tt.want, resp)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			claims := tokenClaims(t, deps, resp["access_token"].(string))
+			if claims["sub"] != "alice" {
+				t.Errorf("sub = %v, want alice", claims["sub"])
+			}
+			if act, ok := claims["act"]; !reflect.DeepEqual(act, tt.wantAct) || ok != (tt.wantAct != nil) {
+				t.Errorf("act = %v, want %v", act, tt.wantAct)
+			}
+			if _, ok := claims["may_act"]; ok {
+				t.Error("may_act copied into the exchanged token")
+			}
+		})
+	}
+}
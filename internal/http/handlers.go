@@ -1,60 +1,108 @@
 package http
 
 import (
-	"crypto/rsa"
 	"fmt"
-	"jwtea/internal/keys"
 	"net/http"
 	"net/url"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"jwtea/internal/config"
 	"jwtea/internal/core"
+	"jwtea/internal/keys"
+	"jwtea/internal/pki"
 )
 
 type oidcDiscovery struct {
-	Issuer                           string   `json:"issuer"`
-	JWKSURI                          string   `json:"jwks_uri"`
-	ResponseTypesSupported           []string `json:"response_types_supported"`
-	GrantTypesSupported              []string `json:"grant_types_supported,omitempty"`
-	SubjectTypesSupported            []string `json:"subject_types_supported"`
-	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
-	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
-	ClaimsSupported                  []string `json:"claims_supported,omitempty"`
-	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
-	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
-	TokenEndpointAuthMethods         []string `json:"token_endpoint_auth_methods_supported,omitempty"`
-	CodeChallengeMethodsSupported    []string `json:"code_challenge_methods_supported,omitempty"`
-	IntrospectionEndpoint            string   `json:"introspection_endpoint,omitempty"`
-	RevocationEndpoint               string   `json:"revocation_endpoint,omitempty"`
-	RevocationEndpointAuthMethods    []string `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	Issuer                           string            `json:"issuer"`
+	JWKSURI                          string            `json:"jwks_uri"`
+	ResponseTypesSupported           []string          `json:"response_types_supported"`
+	ResponseModesSupported           []string          `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported              []string          `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported            []string          `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string          `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string          `json:"scopes_supported,omitempty"`
+	ClaimsSupported                  []string          `json:"claims_supported,omitempty"`
+	AuthorizationEndpoint            string            `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string            `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                 string            `json:"userinfo_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint      string            `json:"device_authorization_endpoint,omitempty"`
+	PAREndpoint                      string            `json:"pushed_authorization_request_endpoint,omitempty"`
+	RequirePAR                       bool              `json:"require_pushed_authorization_requests,omitempty"`
+	RequestParameterSupported        bool              `json:"request_parameter_supported,omitempty"`
+	RequestURIParameterSupported     bool              `json:"request_uri_parameter_supported,omitempty"`
+	RequestObjectSigningAlgs         []string          `json:"request_object_signing_alg_values_supported,omitempty"`
+	AuthorizationSigningAlgs         []string          `json:"authorization_signing_alg_values_supported,omitempty"`
+	DPoPSigningAlgs                  []string          `json:"dpop_signing_alg_values_supported,omitempty"`
+	AuthorizationDetailsTypes        []string          `json:"authorization_details_types_supported,omitempty"`
+	EndSessionEndpoint               string            `json:"end_session_endpoint,omitempty"`
+	BackchannelLogoutSupported       bool              `json:"backchannel_logout_supported,omitempty"`
+	FrontchannelLogoutSupported      bool              `json:"frontchannel_logout_supported,omitempty"`
+	TokenEndpointAuthMethods         []string          `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgs     []string          `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported    []string          `json:"code_challenge_methods_supported,omitempty"`
+	IntrospectionEndpoint            string            `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint               string            `json:"revocation_endpoint,omitempty"`
+	RevocationEndpointAuthMethods    []string          `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RegistrationEndpoint             string            `json:"registration_endpoint,omitempty"`
+	MTLSEndpointAliases              map[string]string `json:"mtls_endpoint_aliases,omitempty"`
+	TLSClientCertificateBoundTokens  bool              `json:"tls_client_certificate_bound_access_tokens,omitempty"`
 }
 
 type Dependencies struct {
-	Store   *core.Store
-	Config  *config.Config
-	Chaos   *core.ChaosFlags
-	Issuer  string
-	PrivKey *rsa.PrivateKey
-	Kid     string
+	Store  *core.Store
+	Config *config.Config
+	Chaos  *core.ChaosFlags
+	LogHub *core.LogHub
+	Issuer string
+	Keys   *keys.Store
+	CA     *pki.CA
+}
+
+func authenticateClient(deps *Dependencies, r *http.Request) (core.Client, bool) {
+	cl, method, ok := verifyClientCredentials(deps, r)
+	if !ok {
+		return core.Client{}, false
+	}
+	if cl.TokenEndpointAuthMethod != "" && cl.TokenEndpointAuthMethod != method {
+		return core.Client{}, false
+	}
+	return cl, true
 }
 
-func authenticateClient(s *core.Store, r *http.Request) (core.Client, bool) {
+// verifyClientCredentials authenticates the client and reports which
+// token_endpoint_auth_method it used.
+func verifyClientCredentials(deps *Dependencies, r *http.Request) (core.Client, string, bool) {
+	if r.Form.Get("client_assertion_type") == clientAssertionType {
+		return authenticateClientAssertion(deps, r)
+	}
+	method := "client_secret_basic"
 	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok && r.Form.Get("client_secret") == "" && clientCertificate(r) != nil {
+		if cl, found := deps.Store.GetClient(r.Form.Get("client_id")); found {
+			if method, ok := authenticateClientCertificate(deps, r, cl); ok {
+				return cl, method, true
+			}
+		}
+	}
 	if !ok {
+		method = "client_secret_post"
 		clientID = r.Form.Get("client_id")
 		clientSecret = r.Form.Get("client_secret")
 	}
-	cl, ok := s.GetClient(clientID)
+	cl, ok := deps.Store.GetClient(clientID)
 	if !ok {
-		return core.Client{}, false
+		return core.Client{}, "", false
 	}
-	if cl.Secret != "" && cl.Secret != clientSecret {
-		return core.Client{}, false
+	if cl.Secret == "" {
+		return cl, "none", true
 	}
-	return cl, true
+	if cl.Secret != clientSecret {
+		return core.Client{}, "", false
+	}
+	return cl, method, true
 }
 
 // RootHandler handles / endpoint
@@ -86,19 +134,19 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // JWKSHandler handles /jwks.json endpoint
 type JWKSHandler struct {
-	jwk keys.JwkRSA
+	keys *keys.Store
 }
 
-func NewJWKSHandler(jwk keys.JwkRSA) *JWKSHandler {
-	return &JWKSHandler{jwk: jwk}
+func NewJWKSHandler(ks *keys.Store) *JWKSHandler {
+	return &JWKSHandler{keys: ks}
 }
 
 func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "public, max-age=30")
 	writeJSON(w, struct {
-		Keys []keys.JwkRSA `json:"keys"`
-	}{Keys: []keys.JwkRSA{h.jwk}})
+		Keys []keys.JWK `json:"keys"`
+	}{Keys: h.keys.JWKS()})
 }
 
 // DiscoveryHandler handles /.well-known/openid-configuration endpoint
@@ -119,15 +167,30 @@ func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	conf := oidcDiscovery{
 		Issuer:                           h.issuer,
 		JWKSURI:                          h.issuer + "/jwks.json",
-		ResponseTypesSupported:           []string{"code"},
+		ResponseTypesSupported:           h.config.OAuth.AllowedResponseTypes,
+		ResponseModesSupported:           responseModes,
 		GrantTypesSupported:              h.config.OAuth.AllowedGrantTypes,
 		SubjectTypesSupported:            []string{"public"},
 		IDTokenSigningAlgValuesSupported: []string{h.config.Tokens.Algorithm},
 		ScopesSupported:                  h.config.OAuth.SupportedScopes,
-		ClaimsSupported:                  []string{"iss", "sub", "aud", "exp", "iat"},
+		ClaimsSupported:                  claimsSupported(),
 		AuthorizationEndpoint:            h.issuer + "/authorize",
 		TokenEndpoint:                    h.issuer + "/oauth2/token",
-		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post"},
+		UserinfoEndpoint:                 h.issuer + "/userinfo",
+		DeviceAuthorizationEndpoint:      h.issuer + "/oauth2/device_authorization",
+		PAREndpoint:                      h.issuer + "/oauth2/par",
+		RequirePAR:                       h.config.PAR.Required,
+		RequestParameterSupported:        true,
+		RequestURIParameterSupported:     true,
+		RequestObjectSigningAlgs:         append(keys.SupportedAlgorithms(), "none"),
+		AuthorizationSigningAlgs:         []string{h.config.Tokens.Algorithm},
+		DPoPSigningAlgs:                  dpopAlgorithms(),
+		AuthorizationDetailsTypes:        h.config.OAuth.AuthorizationDetailsTypes,
+		EndSessionEndpoint:               h.issuer + "/logout",
+		BackchannelLogoutSupported:       true,
+		FrontchannelLogoutSupported:      true,
+		TokenEndpointAuthMethods:         []string{"client_secret_basic", "client_secret_post", "client_secret_jwt", "private_key_jwt"},
+		TokenEndpointAuthSigningAlgs:     keys.SupportedAlgorithms(),
 		CodeChallengeMethodsSupported:    []string{"plain", "S256"},
 	}
 	if h.config.Introspection.Enabled {
@@ -135,11 +198,30 @@ func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if h.config.Revocation.Enabled {
 		conf.RevocationEndpoint = h.issuer + "/oauth2/revoke"
-		conf.RevocationEndpointAuthMethods = []string{"client_secret_basic", "client_secret_post"}
+		conf.RevocationEndpointAuthMethods = []string{"client_secret_basic", "client_secret_post", "client_secret_jwt", "private_key_jwt"}
+	}
+	if h.config.Registration.Enabled {
+		conf.RegistrationEndpoint = h.issuer + "/oauth2/register"
+	}
+	if h.config.MTLS.Enabled {
+		conf.TokenEndpointAuthMethods = append(conf.TokenEndpointAuthMethods, "tls_client_auth", "self_signed_tls_client_auth")
+		if conf.RevocationEndpointAuthMethods != nil {
+			conf.RevocationEndpointAuthMethods = append(conf.RevocationEndpointAuthMethods, "tls_client_auth", "self_signed_tls_client_auth")
+		}
+		conf.MTLSEndpointAliases = mtlsEndpointAliases(h.config)
+		conf.TLSClientCertificateBoundTokens = true
 	}
 	writeJSON(w, conf)
 }
 
+func claimsSupported() []string {
+	claims := []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "azp"}
+	for _, scope := range []string{"profile", "email", "phone", "address"} {
+		claims = append(claims, core.ScopeClaims[scope]...)
+	}
+	return claims
+}
+
 // AuthorizeHandler handles /authorize endpoint
 type AuthorizeHandler struct {
 	deps *Dependencies
@@ -156,7 +238,7 @@ func (h *AuthorizeHandler) resolveUserID(loginHint string) string {
 		}
 	}
 
-	users := h.deps.Store.ListUsers()
+	users := sortedUsers(h.deps.Store)
 	if len(users) > 0 {
 		return users[0].Email
 	}
@@ -165,40 +247,167 @@ func (h *AuthorizeHandler) resolveUserID(loginHint string) string {
 }
 
 func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/authorize/login":
+		h.handleLogin(w, r)
+		return
+	case "/authorize/consent":
+		h.handleConsent(w, r)
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	q := r.URL.Query()
-	responseType := q.Get("response_type")
+	pushed, signed := false, false
+	if requestURI := q.Get("request_uri"); strings.HasPrefix(requestURI, requestURIPrefix) {
+		pr, ok := h.deps.Store.TakePushedRequest(requestURI, q.Get("client_id"))
+		if !ok {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request_uri", "request_uri unknown, expired or issued to another client")
+			return
+		}
+		q = pr.Params
+		pushed, signed = true, pr.Signed
+	} else {
+		resolved, isSigned, aerr := h.requestObject(q)
+		if aerr != nil {
+			h.errorRedirect(w, r, aerr.ar, aerr.code, aerr.desc)
+			return
+		}
+		q, signed = resolved, isSigned
+	}
+
+	ar, maxAge, aerr := h.parseAuthorizeRequest(q, pushed, signed)
+	if aerr != nil {
+		h.errorRedirect(w, r, aerr.ar, aerr.code, aerr.desc)
+		return
+	}
+
+	if sess, ok := h.reusableSession(r, ar, maxAge); ok {
+		ar.UserID = sess.UserID
+		ar.AuthTime = sess.AuthTime
+		h.continueAuthorization(w, r, ar)
+		return
+	}
+	if hasPrompt(ar.Prompt, "none") {
+		h.errorRedirect(w, r, ar, "login_required", "no session satisfies the request")
+		return
+	}
+
+	if h.deps.Config.Login.AutoLogin {
+		ar.UserID = h.resolveUserID(ar.LoginHint)
+		ar.AuthTime = time.Now()
+		if err := h.startSession(w, r, ar.UserID, ar.AuthTime); err != nil {
+			h.errorRedirect(w, r, ar, "server_error", "session creation failed")
+			return
+		}
+		h.continueAuthorization(w, r, ar)
+		return
+	}
+
+	ar, err := h.saveAuthRequest(ar)
+	if err != nil {
+		h.errorRedirect(w, r, ar, "server_error", "request id generation failed")
+		return
+	}
+	h.renderLogin(w, http.StatusOK, ar, "")
+}
+
+// authorizeError is a problem with authorization request parameters, with
+// where to report it.
+type authorizeError struct {
+	ar         core.AuthRequest
+	code, desc string
+}
+
+// parseAuthorizeRequest validates authorization request parameters, from the
+// /authorize query or a pushed authorization request, and returns the request
+// with its max_age (-1 when absent). pushed and signed say whether they came
+// from /oauth2/par and from a signed request object.
+func (h *AuthorizeHandler) parseAuthorizeRequest(q url.Values, pushed, signed bool) (core.AuthRequest, int, *authorizeError) {
+	responseType := normalizeResponseType(q.Get("response_type"))
 	clientID := q.Get("client_id")
 	redirectURI := q.Get("redirect_uri")
 	scope := q.Get("scope")
 	state := q.Get("state")
 	codeChallenge := q.Get("code_challenge")
 	codeChallengeMethod := q.Get("code_challenge_method")
+	responseMode := q.Get("response_mode")
 
-	if responseType != "code" || clientID == "" || redirectURI == "" {
-		OAuthErrorRedirect(w, r, redirectURI, state, "invalid_request", "missing or invalid parameters")
-		return
+	// Until the redirect_uri is known to be registered for the client,
+	// errors are shown to the user agent rather than sent anywhere.
+	if responseType == "" || clientID == "" || redirectURI == "" {
+		return core.AuthRequest{}, 0, &authorizeError{code: "invalid_request", desc: "missing or invalid parameters"}
 	}
 
 	if _, err := url.ParseRequestURI(redirectURI); err != nil {
-		OAuthErrorRedirect(w, r, redirectURI, state, "invalid_request", "invalid redirect_uri")
-		return
+		return core.AuthRequest{}, 0, &authorizeError{code: "invalid_request", desc: "invalid redirect_uri"}
 	}
 
 	cl, ok := h.deps.Store.GetClient(clientID)
 	if !ok || !RedirectAllowed(cl, redirectURI) {
-		OAuthErrorRedirect(w, r, redirectURI, state, "unauthorized_client", "client or redirect_uri not allowed")
-		return
+		return core.AuthRequest{}, 0, &authorizeError{code: "unauthorized_client", desc: "client or redirect_uri not allowed"}
+	}
+
+	base := core.AuthRequest{ClientID: clientID, RedirectURI: redirectURI, State: state}
+	if validResponseMode(responseMode) {
+		base.ResponseMode = responseMode
+	}
+
+	if !validResponseMode(responseMode) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "unsupported response_mode"}
+	}
+
+	if !pushed && (h.deps.Config.PAR.Required || cl.RequirePushedAuthorizationRequests) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "pushed authorization request required"}
+	}
+	if !signed && cl.RequireSignedRequestObject {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "signed request object required"}
+	}
+
+	if !responseTypeAllowed(h.deps.Config, responseType) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "unsupported_response_type", desc: "response_type not supported"}
+	}
+	base.ResponseType = responseType
+	withCode := hasResponseType(responseType, "code")
+	if !clientAllowsResponseType(h.deps.Config, cl, responseType) || (withCode && !clientAllowsGrant(h.deps.Config, cl, "authorization_code")) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "unauthorized_client", desc: "client may not use this response_type"}
+	}
+	// Tokens returned from /authorize must not end up in the query string,
+	// where they leak into logs and Referer headers. A signed but unencrypted
+	// JARM response does not hide them either (JARM section 2.3.1).
+	if responseType != "code" && (responseMode == "query" || responseMode == "query.jwt") {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "response_mode=" + responseMode + " is not allowed for this response_type"}
+	}
+	if !scopeAllowed(h.deps.Config, cl, scope) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_scope", desc: "requested scope is not allowed"}
+	}
+	resources, ok := targetResources(h.deps.Store, q["resource"])
+	if !ok {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_target", desc: "unknown resource"}
+	}
+	if len(resources) > 0 && !resourcesOfferScope(resources, scope) {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_scope", desc: "requested scope is not offered by the resource"}
+	}
+	var details []map[string]any
+	if raw := q.Get("authorization_details"); raw != "" {
+		var err error
+		if details, err = parseAuthorizationDetails(h.deps.Config, raw); err != nil {
+			return core.AuthRequest{}, 0, &authorizeError{ar: base, code: invalidAuthorizationDetails, desc: err.Error()}
+		}
+	}
+	if hasResponseType(responseType, "id_token") && !HasScope(scope, "openid") {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "the id_token response type requires the openid scope"}
+	}
+	if responseType != "code" && responseType != "token" && q.Get("nonce") == "" {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "nonce required for this response_type"}
 	}
 
 	isPublicClient := cl.Secret == ""
 	pkceRequired := h.deps.Config.OAuth.PKCERequired || (h.deps.Config.OAuth.PKCERequiredForPublic && isPublicClient)
-	if pkceRequired && codeChallenge == "" {
-		OAuthErrorRedirect(w, r, redirectURI, state, "invalid_request", "code_challenge required")
-		return
+	if withCode && pkceRequired && codeChallenge == "" {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "code_challenge required"}
 	}
 
 	if codeChallenge != "" {
@@ -206,40 +415,136 @@ func (h *AuthorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			codeChallengeMethod = "plain"
 		}
 		if codeChallengeMethod != "plain" && codeChallengeMethod != "S256" {
-			OAuthErrorRedirect(w, r, redirectURI, state, "invalid_request", "unsupported code_challenge_method")
-			return
+			return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "unsupported code_challenge_method"}
 		}
 	}
 
-	code, err := RandCode(32)
-	if err != nil {
-		OAuthErrorRedirect(w, r, redirectURI, state, "server_error", "code generation failed")
-		return
+	prompt := q.Get("prompt")
+	if hasPrompt(prompt, "none") && strings.TrimSpace(prompt) != "none" {
+		return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "prompt=none cannot be combined with other values"}
 	}
+	maxAge := -1
+	if v := q.Get("max_age"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return core.AuthRequest{}, 0, &authorizeError{ar: base, code: "invalid_request", desc: "invalid max_age"}
+		}
+		maxAge = n
+	}
+
+	ar := core.AuthRequest{
+		ClientID:             cl.ID,
+		RedirectURI:          redirectURI,
+		Scope:                scope,
+		State:                state,
+		Nonce:                q.Get("nonce"),
+		LoginHint:            q.Get("login_hint"),
+		Prompt:               prompt,
+		CodeChallenge:        codeChallenge,
+		CodeChallengeMethod:  codeChallengeMethod,
+		ResponseType:         responseType,
+		ResponseMode:         responseMode,
+		Resources:            resourceIdentifiers(resources),
+		AuthorizationDetails: details,
+	}
+	return ar, maxAge, nil
+}
+
+// saveAuthRequest stores a pending authorization request, giving it an ID
+// and a fresh expiry.
+func (h *AuthorizeHandler) saveAuthRequest(ar core.AuthRequest) (core.AuthRequest, error) {
+	if ar.ID == "" {
+		id, err := RandCode(24)
+		if err != nil {
+			return ar, err
+		}
+		ar.ID = id
+	}
+	ar.ExpiresAt = time.Now().Add(h.deps.Config.Login.RequestExpiry.Duration)
+	h.deps.Store.SaveAuthRequest(ar)
+	return ar, nil
+}
+
+// issueResponse completes an authorization request for its signed-in user
+// and sends the client what its response_type asks for: an authorization
+// code, tokens straight from /authorize (implicit), or both (hybrid).
+func (h *AuthorizeHandler) issueResponse(w http.ResponseWriter, r *http.Request, ar core.AuthRequest) {
+	params := url.Values{}
+
+	var code string
+	if hasResponseType(ar.ResponseType, "code") {
+		var err error
+		code, err = RandCode(32)
+		if err != nil {
+			h.errorRedirect(w, r, ar, "server_error", "code generation failed")
+			return
+		}
 
-	userID := h.resolveUserID(q.Get("login_hint"))
+		ac := core.AuthCode{
+			Code:                 code,
+			ClientID:             ar.ClientID,
+			RedirectURI:          ar.RedirectURI,
+			Scope:                ar.Scope,
+			State:                ar.State,
+			UserID:               ar.UserID,
+			ExpiresAt:            time.Now().Add(h.deps.Config.OAuth.AuthCodeExpiry.Duration),
+			CodeChallenge:        ar.CodeChallenge,
+			CodeChallengeMethod:  ar.CodeChallengeMethod,
+			Nonce:                ar.Nonce,
+			AuthTime:             ar.AuthTime,
+			Resources:            ar.Resources,
+			AuthorizationDetails: ar.AuthorizationDetails,
+		}
+		h.deps.Store.SaveCode(ac)
+		params.Set("code", code)
+	}
 
-	ac := core.AuthCode{
-		Code:                code,
-		ClientID:            cl.ID,
-		RedirectURI:         redirectURI,
-		Scope:               scope,
-		State:               state,
-		UserID:              userID,
-		ExpiresAt:           time.Now().Add(h.deps.Config.OAuth.AuthCodeExpiry.Duration),
-		CodeChallenge:       codeChallenge,
-		CodeChallengeMethod: codeChallengeMethod,
+	withToken := hasResponseType(ar.ResponseType, "token")
+	withIDToken := hasResponseType(ar.ResponseType, "id_token")
+	if withToken || withIDToken {
+		cl, _ := h.deps.Store.GetClient(ar.ClientID)
+		resources, _ := targetResources(h.deps.Store, ar.Resources)
+		gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
+		req := core.TokenRequest{
+			Subject:               ar.UserID,
+			Audience:              tokenAudience(h.deps, cl, resources),
+			ClientID:              ar.ClientID,
+			Scope:                 ar.Scope,
+			ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+			IDTokenExpiresIn:      h.deps.Config.Tokens.IDTokenExpiry.Duration,
+			Nonce:                 ar.Nonce,
+			Code:                  code,
+			IDTokenOnly:           !withToken,
+			AuthTime:              ar.AuthTime,
+			UserClaims:            userClaims(h.deps.Store, ar.UserID, ar.Scope),
+			AuthorizationDetails:  ar.AuthorizationDetails,
+			ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
+			ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
+		}
+		applyAccessTokenProfile(h.deps, cl, &req)
+		result, err := gen.Generate(req)
+		if err != nil {
+			h.errorRedirect(w, r, ar, "server_error", "token generation failed")
+			return
+		}
+		if withToken {
+			params.Set("access_token", result.AccessToken)
+			params.Set("token_type", "Bearer")
+			params.Set("expires_in", strconv.FormatInt(result.ExpiresIn, 10))
+			if ar.Scope != "" {
+				params.Set("scope", ar.Scope)
+			}
+		}
+		if withIDToken {
+			params.Set("id_token", result.IDToken)
+		}
+		h.deps.Store.TrackUserClient(ar.UserID, ar.ClientID)
 	}
-	h.deps.Store.SaveCode(ac)
 
-	u, _ := url.Parse(redirectURI)
-	params := u.Query()
-	params.Set("code", code)
-	if state != "" {
-		params.Set("state", state)
+	if ar.State != "" {
+		params.Set("state", ar.State)
 	}
-	u.RawQuery = params.Encode()
-	http.Redirect(w, r, u.String(), http.StatusFound)
+	h.respond(w, r, ar, params)
 }
 
 // TokenHandler handles /oauth2/token endpoint
@@ -261,6 +566,14 @@ func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	grantType := r.Form.Get("grant_type")
+	if grantType != "" && !slices.Contains(h.deps.Config.OAuth.AllowedGrantTypes, grantType) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "unsupported_grant_type", "grant type not allowed")
+		return
+	}
+	r, ok := h.checkDPoP(w, r)
+	if !ok {
+		return
+	}
 
 	switch grantType {
 	case "authorization_code":
@@ -269,18 +582,29 @@ func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.handleClientCredentials(w, r)
 	case "refresh_token":
 		h.handleRefreshToken(w, r)
+	case deviceCodeGrantType:
+		h.handleDeviceCode(w, r)
+	case tokenExchangeGrantType:
+		h.handleTokenExchange(w, r)
+	case jwtBearerGrantType:
+		h.handleJWTBearer(w, r)
+	case "password":
+		h.handlePassword(w, r)
 	default:
 		WriteOAuthErrorJSON(w, http.StatusBadRequest, "unsupported_grant_type", "grant type not supported")
 	}
 }
 
 func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Request) {
-	cl, ok := authenticateClient(h.deps.Store, r)
+	cl, ok := authenticateClient(h.deps, r)
 	if !ok {
 		w.Header().Set("WWW-Authenticate", "Basic realm=token")
 		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
 		return
 	}
+	if !requireGrant(w, h.deps.Config, cl, "authorization_code") {
+		return
+	}
 
 	code := r.Form.Get("code")
 	redirectURI := r.Form.Get("redirect_uri")
@@ -308,16 +632,34 @@ func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Re
 		}
 	}
 
-	gen := core.NewTokenGenerator(h.deps.PrivKey, h.deps.Kid, h.deps.Issuer)
+	resources, ok := requireResources(w, r, h.deps.Store, ac.Resources, ac.Scope)
+	if !ok {
+		return
+	}
+	details, ok := requireGrantedAuthorizationDetails(w, r, h.deps.Config, ac.AuthorizationDetails)
+	if !ok {
+		return
+	}
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
 	req := core.TokenRequest{
 		Subject:               ac.UserID,
-		Audience:              cl.ID,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
 		Scope:                 ac.Scope,
-		ExpiresIn:             h.deps.Config.Tokens.AccessTokenExpiry.Duration,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		IDTokenExpiresIn:      h.deps.Config.Tokens.IDTokenExpiry.Duration,
+		Nonce:                 ac.Nonce,
+		AuthTime:              ac.AuthTime,
+		UserClaims:            userClaims(h.deps.Store, ac.UserID, ac.Scope),
+		AuthorizationDetails:  details,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
 		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
 		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
 	}
 
+	applyAccessTokenProfile(h.deps, cl, &req)
 	result, err := gen.Generate(req)
 	if err != nil {
 		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
@@ -326,25 +668,34 @@ func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Re
 
 	resp := map[string]any{
 		"access_token": result.AccessToken,
-		"token_type":   "Bearer",
+		"token_type":   accessTokenType(r),
 		"expires_in":   result.ExpiresIn,
 		"scope":        ac.Scope,
 		"id_token":     result.IDToken,
 	}
+	if len(details) > 0 {
+		resp["authorization_details"] = details
+	}
+	h.deps.Store.TrackUserClient(ac.UserID, cl.ID)
 
-	if h.deps.Config.Tokens.IssueRefreshToken || HasScope(ac.Scope, "offline_access") {
+	if issuesRefreshToken(h.deps.Config, cl, ac.Scope) {
 		refreshToken, err := GenerateRefreshToken()
 		if err != nil {
 			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "refresh token generation failed")
 			return
 		}
 		rt := core.RefreshToken{
-			Token:     refreshToken,
-			ClientID:  cl.ID,
-			UserID:    ac.UserID,
-			Scope:     ac.Scope,
-			ExpiresAt: time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
-			IssuedAt:  time.Now(),
+			Token:                refreshToken,
+			ClientID:             cl.ID,
+			UserID:               ac.UserID,
+			Scope:                ac.Scope,
+			AuthTime:             ac.AuthTime,
+			ExpiresAt:            time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
+			IssuedAt:             time.Now(),
+			Resources:            ac.Resources,
+			AuthorizationDetails: ac.AuthorizationDetails,
+			JKT:                  refreshTokenJKT(r, cl),
+			X5T:                  refreshTokenX5T(r, cl),
 		}
 		h.deps.Store.SaveRefreshToken(rt)
 		resp["refresh_token"] = refreshToken
@@ -355,28 +706,47 @@ func (h *TokenHandler) handleAuthorizationCode(w http.ResponseWriter, r *http.Re
 }
 
 func (h *TokenHandler) handleClientCredentials(w http.ResponseWriter, r *http.Request) {
-	cl, ok := authenticateClient(h.deps.Store, r)
+	cl, ok := authenticateClient(h.deps, r)
 	if !ok {
 		w.Header().Set("WWW-Authenticate", "Basic realm=token")
 		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
 		return
 	}
+	if !requireGrant(w, h.deps.Config, cl, "client_credentials") {
+		return
+	}
 
 	scope := r.Form.Get("scope")
 	if scope == "" {
 		scope = strings.Join(h.deps.Config.OAuth.DefaultScopes, " ")
 	}
+	if !requireScope(w, h.deps.Config, cl, scope) {
+		return
+	}
+	resources, ok := requireResources(w, r, h.deps.Store, nil, scope)
+	if !ok {
+		return
+	}
+	details, ok := requireAuthorizationDetails(w, r, h.deps.Config)
+	if !ok {
+		return
+	}
 
-	gen := core.NewTokenGenerator(h.deps.PrivKey, h.deps.Kid, h.deps.Issuer)
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
 	req := core.TokenRequest{
 		Subject:               cl.ID,
-		Audience:              cl.ID,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
 		Scope:                 scope,
-		ExpiresIn:             h.deps.Config.Tokens.AccessTokenExpiry.Duration,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		AuthorizationDetails:  details,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
 		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
 		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
 	}
 
+	applyAccessTokenProfile(h.deps, cl, &req)
 	result, err := gen.Generate(req)
 	if err != nil {
 		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
@@ -385,22 +755,28 @@ func (h *TokenHandler) handleClientCredentials(w http.ResponseWriter, r *http.Re
 
 	resp := map[string]any{
 		"access_token": result.AccessToken,
-		"token_type":   "Bearer",
+		"token_type":   accessTokenType(r),
 		"expires_in":   result.ExpiresIn,
 		"scope":        scope,
 	}
+	if len(details) > 0 {
+		resp["authorization_details"] = details
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	writeJSON(w, resp)
 }
 
 func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
-	cl, ok := authenticateClient(h.deps.Store, r)
+	cl, ok := authenticateClient(h.deps, r)
 	if !ok {
 		w.Header().Set("WWW-Authenticate", "Basic realm=token")
 		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
 		return
 	}
+	if !requireGrant(w, h.deps.Config, cl, "refresh_token") {
+		return
+	}
 
 	refreshTokenStr := r.Form.Get("refresh_token")
 	if refreshTokenStr == "" {
@@ -413,6 +789,14 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "refresh token invalid or expired")
 		return
 	}
+	if rt.JKT != "" && rt.JKT != dpopJKT(r) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "refresh token is bound to another DPoP key")
+		return
+	}
+	if rt.X5T != "" && rt.X5T != certThumbprint(r) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "refresh token is bound to another client certificate")
+		return
+	}
 
 	requestedScope := r.Form.Get("scope")
 	scope := rt.Scope
@@ -424,16 +808,33 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 		scope = requestedScope
 	}
 
-	gen := core.NewTokenGenerator(h.deps.PrivKey, h.deps.Kid, h.deps.Issuer)
+	resources, ok := requireResources(w, r, h.deps.Store, rt.Resources, scope)
+	if !ok {
+		return
+	}
+	details, ok := requireGrantedAuthorizationDetails(w, r, h.deps.Config, rt.AuthorizationDetails)
+	if !ok {
+		return
+	}
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
 	req := core.TokenRequest{
 		Subject:               rt.UserID,
-		Audience:              cl.ID,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
 		Scope:                 scope,
-		ExpiresIn:             h.deps.Config.Tokens.AccessTokenExpiry.Duration,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		IDTokenExpiresIn:      h.deps.Config.Tokens.IDTokenExpiry.Duration,
+		AuthTime:              rt.AuthTime,
+		UserClaims:            userClaims(h.deps.Store, rt.UserID, scope),
+		AuthorizationDetails:  details,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
 		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
 		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
 	}
 
+	applyAccessTokenProfile(h.deps, cl, &req)
 	result, err := gen.Generate(req)
 	if err != nil {
 		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
@@ -442,10 +843,17 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 
 	resp := map[string]any{
 		"access_token": result.AccessToken,
-		"token_type":   "Bearer",
+		"token_type":   accessTokenType(r),
 		"expires_in":   result.ExpiresIn,
 		"scope":        scope,
 	}
+	if HasScope(scope, "openid") {
+		resp["id_token"] = result.IDToken
+	}
+	if len(details) > 0 {
+		resp["authorization_details"] = details
+	}
+	h.deps.Store.TrackUserClient(rt.UserID, cl.ID)
 
 	if h.deps.Config.Tokens.RefreshTokenRotation {
 		h.deps.Store.RevokeRefreshToken(refreshTokenStr)
@@ -455,12 +863,17 @@ func (h *TokenHandler) handleRefreshToken(w http.ResponseWriter, r *http.Request
 			return
 		}
 		newRT := core.RefreshToken{
-			Token:     newRefreshToken,
-			ClientID:  cl.ID,
-			UserID:    rt.UserID,
-			Scope:     rt.Scope,
-			ExpiresAt: time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
-			IssuedAt:  time.Now(),
+			Token:                newRefreshToken,
+			ClientID:             cl.ID,
+			UserID:               rt.UserID,
+			Scope:                rt.Scope,
+			AuthTime:             rt.AuthTime,
+			ExpiresAt:            time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
+			IssuedAt:             time.Now(),
+			Resources:            rt.Resources,
+			AuthorizationDetails: rt.AuthorizationDetails,
+			JKT:                  rt.JKT,
+			X5T:                  rt.X5T,
 		}
 		h.deps.Store.SaveRefreshToken(newRT)
 		resp["refresh_token"] = newRefreshToken
@@ -491,7 +904,7 @@ func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 	}
 
 	if h.deps.Config.Introspection.RequireClientAuth {
-		cl, ok := authenticateClient(h.deps.Store, r)
+		cl, ok := authenticateClient(h.deps, r)
 		if !ok {
 			w.Header().Set("WWW-Authenticate", "Basic realm=introspect")
 			WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication required")
@@ -516,7 +929,7 @@ func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *IntrospectionHandler) introspectToken(tokenStr string) map[string]any {
-	claims, err := core.ParseAndValidateToken(tokenStr, &h.deps.PrivKey.PublicKey)
+	claims, err := core.ParseAndValidateToken(tokenStr, h.deps.Keys)
 	if err != nil {
 		return map[string]any{"active": false}
 	}
@@ -535,8 +948,23 @@ func (h *IntrospectionHandler) introspectToken(tokenStr string) map[string]any {
 	if sub, ok := claims["sub"].(string); ok {
 		resp["sub"] = sub
 	}
-	if aud, ok := claims["aud"].(string); ok {
-		resp["client_id"] = aud
+	if clientID, ok := claims["client_id"].(string); ok {
+		resp["client_id"] = clientID
+	}
+	if aud, ok := claims["aud"]; ok {
+		resp["aud"] = aud
+	}
+	if act, ok := claims["act"]; ok {
+		resp["act"] = act
+	}
+	if details, ok := claims["authorization_details"]; ok {
+		resp["authorization_details"] = details
+	}
+	if cnf, ok := claims["cnf"].(map[string]any); ok {
+		resp["cnf"] = cnf
+		if _, ok := cnf["jkt"]; ok {
+			resp["token_type"] = "DPoP"
+		}
 	}
 	if scope, ok := claims["scope"].(string); ok {
 		resp["scope"] = scope
@@ -576,7 +1004,7 @@ func (h *RevocationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var clientID string
 	if h.deps.Config.Revocation.RequireClientAuth {
-		cl, ok := authenticateClient(h.deps.Store, r)
+		cl, ok := authenticateClient(h.deps, r)
 		if !ok {
 			w.Header().Set("WWW-Authenticate", "Basic realm=revoke")
 			WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication required")
@@ -610,10 +1038,10 @@ func (h *RevocationHandler) revokeToken(tokenStr, tokenTypeHint, clientID string
 	}
 
 	if tokenTypeHint == "access_token" || tokenTypeHint == "" {
-		claims, err := core.ParseAndValidateToken(tokenStr, &h.deps.PrivKey.PublicKey)
+		claims, err := core.ParseAndValidateToken(tokenStr, h.deps.Keys)
 		if err == nil {
 			if clientID != "" {
-				if aud, ok := claims["aud"].(string); ok && aud != clientID {
+				if owner, _ := claims["client_id"].(string); owner != clientID {
 					return
 				}
 			}
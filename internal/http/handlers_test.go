@@ -0,0 +1,181 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+// resourceToken issues a client_credentials token for clientID aimed at
+// resources.
+func resourceToken(t *testing.T, deps *Dependencies, clientID string, resources ...string) string {
+	t.Helper()
+	w := postForm(NewTokenHandler(deps), "/oauth2/token", url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {"secret"},
+		"resource":      resources,
+	}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("token: status %d, body %s", w.Code, w.Body)
+	}
+	at, _ := decodeJSON(t, w)["access_token"].(string)
+	return at
+}
+
+func introspect(t *testing.T, deps *Dependencies, token string) map[string]any {
+	t.Helper()
+	w := postForm(NewIntrospectionHandler(deps), "/oauth2/introspect", url.Values{
+		"client_id":     {"owner"},
+		"client_secret": {"secret"},
+		"token":         {token},
+	}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("introspect: status %d, body %s", w.Code, w.Body)
+	}
+	return decodeJSON(t, w)
+}
+
+func newResourceDeps(t *testing.T) *Dependencies {
+	t.Helper()
+	deps := newTestDeps(t)
+	deps.Store.AddClient(core.Client{ID: "owner", Secret: "secret"})
+	deps.Store.AddClient(core.Client{ID: "other", Secret: "secret"})
+	deps.Store.AddResource(core.Resource{Identifier: "https://api.example.com"})
+	deps.Store.AddResource(core.Resource{Identifier: "https://files.example.com"})
+	return deps
+}
+
+func TestIntrospectionClientID(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+	}{
+		{"client audience", nil},
+		{"one resource", []string{"https://api.example.com"}},
+		{"several resources", []string{"https://api.example.com", "https://files.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newResourceDeps(t)
+			resp := introspect(t, deps, resourceToken(t, deps, "owner", tt.resources...))
+			if resp["active"] != true {
+				t.Fatalf("token inactive: %v", resp)
+			}
+			if resp["client_id"] != "owner" {
+				t.Errorf("client_id = %v, want owner", resp["client_id"])
+			}
+		})
+	}
+}
+
+func TestRevocationOwnership(t *testing.T) {
+	tests := []struct {
+		name       string
+		resources  []string
+		revokedBy  string
+		wantActive bool
+	}{
+		{"owner, client audience", nil, "owner", false},
+		{"owner, one resource", []string{"https://api.example.com"}, "owner", false},
+		{"owner, several resources", []string{"https://api.example.com", "https://files.example.com"}, "owner", false},
+		{"other client, client audience", nil, "other", true},
+		{"other client, one resource", []string{"https://api.example.com"}, "other", true},
+		{"other client, several resources", []string{"https://api.example.com", "https://files.example.com"}, "other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newResourceDeps(t)
+			at := resourceToken(t, deps, "owner", tt.resources...)
+			w := postForm(NewRevocationHandler(deps), "/oauth2/revoke", url.Values{
+				"client_id":       {tt.revokedBy},
+				"client_secret":   {"secret"},
+				"token":           {at},
+				"token_type_hint": {"access_token"},
+			}, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("revoke: status %d, body %s", w.Code, w.Body)
+			}
+			if active := introspect(t, deps, at)["active"]; active != tt.wantActive {
+				t.Errorf("active = %v, want %v", active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestCodeGrantIDToken(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	tests := []struct {
+		name      string
+		nonce     string
+		scope     string
+		wantClaim map[string]any
+		absent    []string
+	}{
+		{"nonce", "n-0S6_WzA2Mj", "openid", map[string]any{"nonce": "n-0S6_WzA2Mj"}, []string{"email", "name"}},
+		{"no nonce", "", "openid", nil, []string{"nonce"}},
+		{"profile and email claims", "n-1", "openid profile email", map[string]any{"name": "Alice Liddell", "email": "alice@example.com"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Tokens.IDTokenExpiry.Duration = 5 * time.Minute
+			deps.Store.AddUser(core.User{Email: "alice@example.com", Name: "Alice Liddell"})
+			deps.Store.AddClient(core.Client{ID: "web", Secret: "secret", RedirectURIs: []string{"https://rp.example.com/cb"}})
+			deps.Store.SaveCode(core.AuthCode{
+				Code:        "code-1",
+				ClientID:    "web",
+				RedirectURI: "https://rp.example.com/cb",
+				Scope:       tt.scope,
+				UserID:      "alice@example.com",
+				ExpiresAt:   time.Now().Add(time.Minute),
+				Nonce:       tt.nonce,
+				AuthTime:    authTime,
+			})
+
+			w := postForm(NewTokenHandler(deps), "/oauth2/token", url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {"code-1"},
+				"redirect_uri":  {"https://rp.example.com/cb"},
+				"client_id":     {"web"},
+				"client_secret": {"secret"},
+			}, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			resp := decodeJSON(t, w)
+			idToken, _ := resp["id_token"].(string)
+			at, _ := resp["access_token"].(string)
+			claims := tokenClaims(t, deps, idToken)
+
+			atHash, _ := core.TokenHash(at, "RS256")
+			want := map[string]any{
+				"aud":       "web",
+				"azp":       "web",
+				"auth_time": float64(authTime.Unix()),
+				"at_hash":   atHash,
+			}
+			for k, v := range tt.wantClaim {
+				want[k] = v
+			}
+			for k, v := range want {
+				if claims[k] != v {
+					t.Errorf("%s = %v, want %v", k, claims[k], v)
+				}
+			}
+			for _, k := range tt.absent {
+				if v, ok := claims[k]; ok {
+					t.Errorf("%s = %v, want it absent", k, v)
+				}
+			}
+			iat, _ := claims["iat"].(float64)
+			exp, _ := claims["exp"].(float64)
+			if ttl := time.Duration(exp-iat) * time.Second; ttl != 5*time.Minute {
+				t.Errorf("ID token lifetime %s, want the configured 5m", ttl)
+			}
+		})
+	}
+}
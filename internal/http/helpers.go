@@ -3,15 +3,16 @@ package http
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"jwtea/internal/core"
 	"log"
 	"net/http"
-	"net/url"
 	"slices"
 	"strings"
+
+	"jwtea/internal/core"
 )
 
 func writeJSON(w http.ResponseWriter, v any) {
@@ -38,6 +39,13 @@ func RedirectAllowed(c core.Client, redirectURI string) bool {
 	return slices.Contains(c.RedirectURIs, redirectURI)
 }
 
+// bearerMatches reports whether r carries token as its Bearer token. An empty
+// token never matches.
+func bearerMatches(r *http.Request, token string) bool {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
 func RandCode(n int) (string, error) {
 	b := make([]byte, n)
 	if _, err := rand.Read(b); err != nil {
@@ -46,28 +54,6 @@ func RandCode(n int) (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-func OAuthErrorRedirect(w http.ResponseWriter, r *http.Request, redirectURI, state, code, desc string) {
-	if redirectURI == "" {
-		WriteOAuthErrorJSON(w, http.StatusBadRequest, code, desc)
-		return
-	}
-	u, err := url.Parse(redirectURI)
-	if err != nil {
-		WriteOAuthErrorJSON(w, http.StatusBadRequest, code, desc)
-		return
-	}
-	q := u.Query()
-	q.Set("error", code)
-	if desc != "" {
-		q.Set("error_description", desc)
-	}
-	if state != "" {
-		q.Set("state", state)
-	}
-	u.RawQuery = q.Encode()
-	http.Redirect(w, r, u.String(), http.StatusFound)
-}
-
 func WriteOAuthErrorJSON(w http.ResponseWriter, status int, code, desc string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -113,3 +99,12 @@ func IsScopeSubset(requested, original string) bool {
 func HasScope(scopeStr, target string) bool {
 	return slices.Contains(strings.Fields(scopeStr), target)
 }
+
+// userClaims returns the ID token claims the scope releases for a store user.
+func userClaims(s *core.Store, userID, scope string) map[string]any {
+	u, ok := s.GetUser(userID)
+	if !ok {
+		return nil
+	}
+	return core.UserClaims(u, scope)
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "http://issuer.test"
+
+// newTestDeps returns dependencies with the default config, an empty store
+// and an in-memory RS256 signing key.
+func newTestDeps(t *testing.T) *Dependencies {
+	t.Helper()
+	ks, err := keys.LoadStore("", "RS256", false)
+	if err != nil {
+		t.Fatalf("LoadStore: %v", err)
+	}
+	return &Dependencies{
+		Store:  core.NewStore(),
+		Config: config.DefaultConfig(),
+		Chaos:  core.NewChaosFlags(),
+		LogHub: core.NewLogHub(16),
+		Issuer: testIssuer,
+		Keys:   ks,
+	}
+}
+
+// newECKey generates a P-256 key and returns it with its public key as PEM.
+func newECKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	return priv, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signJWT(t *testing.T, method jwt.SigningMethod, key any, header map[string]any, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(method, claims)
+	for k, v := range header {
+		tok.Header[k] = v
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return s
+}
+
+func postForm(h http.Handler, path string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return out
+}
+
+// tokenClaims returns the claims of a token issued by deps.
+func tokenClaims(t *testing.T, deps *Dependencies, token string) jwt.MapClaims {
+	t.Helper()
+	claims, err := core.ParseAndValidateToken(token, deps.Keys)
+	if err != nil {
+		t.Fatalf("ParseAndValidateToken: %v", err)
+	}
+	return claims
+}
+
+func TestBearerMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  string
+		token string
+		want  bool
+	}{
+		{"match", "Bearer s3cret", "s3cret", true},
+		{"wrong token", "Bearer nope", "s3cret", false},
+		{"basic scheme", "Basic s3cret", "s3cret", false},
+		{"no header", "", "s3cret", false},
+		{"empty token", "Bearer ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			if got := bearerMatches(r, tt.token); got != tt.want {
+				t.Errorf("bearerMatches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
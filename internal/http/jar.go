@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxRequestObjectSize bounds request objects fetched by reference.
+const maxRequestObjectSize = 64 << 10
+
+// requestObjectClaims are JWT claims of a request object that are not
+// authorization request parameters.
+var requestObjectClaims = []string{"iss", "aud", "exp", "iat", "nbf", "jti", "request", "request_uri"}
+
+// requestObject resolves a JAR request object (RFC 9101) passed by value in
+// request or by reference in request_uri. Its claims override the query
+// parameters of the same name. It returns q unchanged when there is none,
+// and reports whether the request object was signed.
+func (h *AuthorizeHandler) requestObject(q url.Values) (url.Values, bool, *authorizeError) {
+	raw := q.Get("request")
+	requestURI := q.Get("request_uri")
+	switch {
+	case raw == "" && requestURI == "":
+		return q, false, nil
+	case raw != "" && requestURI != "":
+		return nil, false, &authorizeError{code: "invalid_request", desc: "request and request_uri cannot both be used"}
+	}
+
+	// The client comes first, so that only the request_uris it registered
+	// are ever fetched.
+	clientID := q.Get("client_id")
+	if clientID == "" && raw != "" {
+		if unverified, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{}); err == nil {
+			clientID, _ = unverified.Claims.(jwt.MapClaims)["client_id"].(string)
+		}
+	}
+	cl, ok := h.deps.Store.GetClient(clientID)
+	if !ok {
+		return nil, false, &authorizeError{code: "invalid_request_object", desc: "unknown client"}
+	}
+	if requestURI != "" {
+		if !slices.Contains(cl.RequestURIs, requestURI) {
+			return nil, false, &authorizeError{code: "invalid_request_uri", desc: "request_uri is not registered for the client"}
+		}
+		fetched, err := fetchRequestObject(requestURI)
+		if err != nil {
+			return nil, false, &authorizeError{code: "invalid_request_uri", desc: err.Error()}
+		}
+		raw = fetched
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		if alg := cl.RequestObjectSigningAlg; alg != "" && t.Method.Alg() != alg {
+			return nil, fmt.Errorf("request object must use %s", alg)
+		}
+		if t.Method == jwt.SigningMethodNone {
+			if cl.RequestObjectSigningAlg != "none" {
+				return nil, fmt.Errorf("unsigned request objects are not accepted for this client")
+			}
+			return jwt.UnsafeAllowNoneSignatureType, nil
+		}
+		return clientVerificationKey(cl, t)
+	},
+		jwt.WithValidMethods(append(keys.SupportedAlgorithms(), "none")),
+		jwt.WithIssuer(cl.ID),
+		jwt.WithAudience(h.deps.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, false, &authorizeError{code: "invalid_request_object", desc: "request object invalid: " + err.Error()}
+	}
+	if id, ok := claims["client_id"].(string); ok && id != cl.ID {
+		return nil, false, &authorizeError{code: "invalid_request_object", desc: "request object client_id does not match"}
+	}
+
+	params := url.Values{}
+	for k, v := range q {
+		params[k] = slices.Clone(v)
+	}
+	params.Del("request")
+	params.Del("request_uri")
+	for k, v := range claims {
+		if slices.Contains(requestObjectClaims, k) {
+			continue
+		}
+		if k == "authorization_details" {
+			// A JSON array too, but a single parameter holding JSON.
+			if b, err := json.Marshal(v); err == nil {
+				params.Set(k, string(b))
+			}
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			params.Set(k, v)
+		case float64:
+			params.Set(k, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			params.Set(k, strconv.FormatBool(v))
+		case []any:
+			// Repeatable parameters such as resource come as arrays.
+			params.Del(k)
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					params.Add(k, s)
+				}
+			}
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			params.Set(k, string(b))
+		}
+	}
+	params.Set("client_id", cl.ID)
+	return params, token.Method != jwt.SigningMethodNone, nil
+}
+
+func fetchRequestObject(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", fmt.Errorf("request_uri must be an http(s) URL")
+	}
+	resp, err := httpClient.Get(uri)
+	if err != nil {
+		return "", fmt.Errorf("fetch request_uri: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch request_uri: %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestObjectSize))
+	if err != nil {
+		return "", fmt.Errorf("fetch request_uri: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,285 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequestObject(t *testing.T) {
+	priv, pub := newECKey(t)
+	otherPriv, _ := newECKey(t)
+
+	claims := func(edit func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"iss":           "jar-client",
+			"aud":           testIssuer,
+			"exp":           time.Now().Add(time.Minute).Unix(),
+			"client_id":     "jar-client",
+			"response_type": "code",
+			"scope":         "openid profile",
+		}
+		if edit != nil {
+			edit(c)
+		}
+		return c
+	}
+	signed := func(c jwt.MapClaims) string { return signJWT(t, jwt.SigningMethodES256, priv, nil, c) }
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		alg        string // the client's request_object_signing_alg
+		wantCode   string
+		wantSigned bool
+		wantParams url.Values
+	}{
+		{
+			name:       "no request object",
+			query:      url.Values{"client_id": {"jar-client"}, "scope": {"openid"}},
+			wantParams: url.Values{"client_id": {"jar-client"}, "scope": {"openid"}},
+		},
+		{
+			name:       "signed",
+			query:      url.Values{"client_id": {"jar-client"}, "scope": {"openid"}, "request": {signed(claims(nil))}},
+			wantSigned: true,
+			wantParams: url.Values{"client_id": {"jar-client"}, "scope": {"openid profile"}, "response_type": {"code"}},
+		},
+		{
+			name: "client_id from the request object",
+			query: url.Values{"request": {signed(claims(func(c jwt.MapClaims) {
+				c["resource"] = []any{"https://a.example", "https://b.example"}
+				c["authorization_details"] = []any{map[string]any{"type": "payment"}}
+			}))}},
+			wantSigned: true,
+			wantParams: url.Values{
+				"client_id":             {"jar-client"},
+				"scope":                 {"openid profile"},
+				"response_type":         {"code"},
+				"resource":              {"https://a.example", "https://b.example"},
+				"authorization_details": {`[{"type":"payment"}]`},
+			},
+		},
+		{
+			name:     "unsigned",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil, claims(nil))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:       "unsigned, client allows none",
+			query:      url.Values{"client_id": {"jar-client"}, "request": {signJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil, claims(nil))}},
+			alg:        "none",
+			wantParams: url.Values{"client_id": {"jar-client"}, "scope": {"openid profile"}, "response_type": {"code"}},
+		},
+		{
+			name:     "signed, client allows only none",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(nil))}},
+			alg:      "none",
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:       "registered algorithm",
+			query:      url.Values{"client_id": {"jar-client"}, "request": {signed(claims(nil))}},
+			alg:        "ES256",
+			wantSigned: true,
+			wantParams: url.Values{"client_id": {"jar-client"}, "scope": {"openid profile"}, "response_type": {"code"}},
+		},
+		{
+			name:     "other than the registered algorithm",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(nil))}},
+			alg:      "ES384",
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "signed by another key",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signJWT(t, jwt.SigningMethodES256, otherPriv, nil, claims(nil))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "foreign audience",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(func(c jwt.MapClaims) { c["aud"] = "https://other.example" }))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "issued by another client",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(func(c jwt.MapClaims) { c["iss"] = "other-client" }))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "no exp",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(func(c jwt.MapClaims) { delete(c, "exp") }))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "expired",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "mismatched client_id claim",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(func(c jwt.MapClaims) { c["client_id"] = "other-client" }))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "unknown client",
+			query:    url.Values{"client_id": {"nobody"}, "request": {signed(claims(nil))}},
+			wantCode: "invalid_request_object",
+		},
+		{
+			name:     "request and request_uri",
+			query:    url.Values{"client_id": {"jar-client"}, "request": {signed(claims(nil))}, "request_uri": {"https://client.example/ro"}},
+			wantCode: "invalid_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddClient(core.Client{ID: "jar-client", PublicKey: pub, RequestObjectSigningAlg: tt.alg})
+			params, isSigned, aerr := NewAuthorizeHandler(deps).requestObject(tt.query)
+			if tt.wantCode != "" {
+				if aerr == nil || aerr.code != tt.wantCode {
+					t.Fatalf("error = %+v, want %s", aerr, tt.wantCode)
+				}
+				return
+			}
+			if aerr != nil {
+				t.Fatalf("unexpected error %s: %s", aerr.code, aerr.desc)
+			}
+			if isSigned != tt.wantSigned {
+				t.Errorf("signed = %v, want %v", isSigned, tt.wantSigned)
+			}
+			for name, want := range tt.wantParams {
+				if got := params[name]; !slices.Equal(got, want) {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if params.Has("request") || params.Has("iss") || params.Has("exp") {
+				t.Errorf("request object claims leaked into params: %v", params)
+			}
+		})
+	}
+}
+
+func TestRequireSignedRequestObject(t *testing.T) {
+	priv, pub := newECKey(t)
+	claims := jwt.MapClaims{
+		"iss":                   "jar-client",
+		"aud":                   testIssuer,
+		"exp":                   time.Now().Add(time.Minute).Unix(),
+		"client_id":             "jar-client",
+		"response_type":         "code",
+		"redirect_uri":          "https://client.example/cb",
+		"scope":                 "openid",
+		"state":                 "xyz",
+		"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		"code_challenge_method": "S256",
+	}
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		alg       string // the client's request_object_signing_alg
+		wantParam string
+	}{
+		{"signed request object", url.Values{"client_id": {"jar-client"}, "request": {signJWT(t, jwt.SigningMethodES256, priv, nil, claims)}}, "", "code"},
+		// With "none" registered the object passes JAR verification, so
+		// require_signed_request_object is what refuses it.
+		{"unsigned request object", url.Values{"client_id": {"jar-client"}, "request": {signJWT(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil, claims)}}, "none", "error"},
+		{"plain parameters", url.Values{
+			"client_id":             {"jar-client"},
+			"response_type":         {"code"},
+			"redirect_uri":          {"https://client.example/cb"},
+			"scope":                 {"openid"},
+			"state":                 {"xyz"},
+			"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+			"code_challenge_method": {"S256"},
+		}, "", "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Login.AutoLogin = true
+			deps.Store.AddUser(core.User{Email: "alice@example.com"})
+			deps.Store.AddClient(core.Client{
+				ID:                         "jar-client",
+				PublicKey:                  pub,
+				RedirectURIs:               []string{"https://client.example/cb"},
+				SkipConsent:                true,
+				RequireSignedRequestObject: true,
+				RequestObjectSigningAlg:    tt.alg,
+			})
+			w := httptest.NewRecorder()
+			NewAuthorizeHandler(deps).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authorize?"+tt.query.Encode(), nil))
+			if w.Code != http.StatusFound {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("Location: %v", err)
+			}
+			if !loc.Query().Has(tt.wantParam) {
+				t.Fatalf("redirect %s has no %s", loc, tt.wantParam)
+			}
+			if got := loc.Query().Get("state"); got != "xyz" {
+				t.Errorf("state = %q, want xyz", got)
+			}
+		})
+	}
+}
+
+func TestRequestObjectByReference(t *testing.T) {
+	priv, pub := newECKey(t)
+	object := signJWT(t, jwt.SigningMethodES256, priv, nil, jwt.MapClaims{
+		"iss":           "jar-client",
+		"aud":           testIssuer,
+		"exp":           time.Now().Add(time.Minute).Unix(),
+		"response_type": "code",
+		"scope":         "openid",
+	})
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_, _ = w.Write([]byte(object))
+	}))
+	defer srv.Close()
+	registered := srv.URL + "/request.jwt"
+
+	tests := []struct {
+		name      string
+		query     url.Values
+		wantCode  string
+		wantFetch bool
+	}{
+		{"registered request_uri", url.Values{"client_id": {"jar-client"}, "request_uri": {registered}}, "", true},
+		{"unregistered request_uri", url.Values{"client_id": {"jar-client"}, "request_uri": {srv.URL + "/other.jwt"}}, "invalid_request_uri", false},
+		{"no client_id", url.Values{"request_uri": {registered}}, "invalid_request_object", false},
+		{"unknown client", url.Values{"client_id": {"nobody"}, "request_uri": {registered}}, "invalid_request_object", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches = 0
+			deps := newTestDeps(t)
+			deps.Store.AddClient(core.Client{ID: "jar-client", PublicKey: pub, RequestURIs: []string{registered}})
+			params, _, aerr := NewAuthorizeHandler(deps).requestObject(tt.query)
+			if tt.wantCode != "" {
+				if aerr == nil || aerr.code != tt.wantCode {
+					t.Fatalf("error = %+v, want %s", aerr, tt.wantCode)
+				}
+			} else if aerr != nil {
+				t.Fatalf("unexpected error %s: %s", aerr.code, aerr.desc)
+			} else if params.Get("scope") != "openid" {
+				t.Errorf("scope = %q, want openid", params.Get("scope"))
+			}
+			if fetched := fetches > 0; fetched != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v", fetched, tt.wantFetch)
+			}
+		})
+	}
+}
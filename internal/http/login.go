@@ -0,0 +1,100 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"time"
+
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+	"jwtea/internal/pages"
+)
+
+func (h *AuthorizeHandler) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	ar, ok := h.deps.Store.GetAuthRequest(r.PostForm.Get("request_id"))
+	if !ok {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "authorization request unknown or expired")
+		return
+	}
+
+	user, ok := h.deps.Store.GetUser(r.PostForm.Get("email"))
+	if !ok {
+		h.renderLogin(w, http.StatusBadRequest, ar, "Choose a user to continue.")
+		return
+	}
+	ar.LoginHint = user.Email
+	if !checkPassword(h.deps.Config, user, r.PostForm.Get("password")) {
+		h.renderLogin(w, http.StatusUnauthorized, ar, "Incorrect password.")
+		return
+	}
+
+	ar.UserID = user.Email
+	ar.AuthTime = time.Now()
+	if err := h.startSession(w, r, ar.UserID, ar.AuthTime); err != nil {
+		h.errorRedirect(w, r, ar, "server_error", "session creation failed")
+		return
+	}
+	h.continueAuthorization(w, r, ar)
+}
+
+// checkPassword checks password against the user's own password when it has
+// one, and against login.password otherwise.
+func checkPassword(cfg *config.Config, user core.User, password string) bool {
+	if user.PasswordHash != "" {
+		return core.CheckPassword(user.PasswordHash, password)
+	}
+	want := cfg.Login.Password
+	if want == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(want)) == 1
+}
+
+func (h *AuthorizeHandler) renderLogin(w http.ResponseWriter, status int, ar core.AuthRequest, errMsg string) {
+	users := sortedUsers(h.deps.Store)
+	selected := ar.LoginHint
+	if _, ok := h.deps.Store.GetUser(selected); !ok && len(users) > 0 {
+		selected = users[0].Email
+	}
+	pages.RenderLogin(w, status, pages.LoginData{
+		RequestID:        ar.ID,
+		ClientID:         ar.ClientID,
+		Scope:            ar.Scope,
+		Users:            users,
+		Selected:         selected,
+		PasswordRequired: passwordRequired(h.deps.Config, users),
+		Error:            errMsg,
+	})
+}
+
+// passwordRequired reports whether the login form needs a password field:
+// login.password is set or one of users has its own password.
+func passwordRequired(cfg *config.Config, users []core.User) bool {
+	if cfg.Login.Password != "" {
+		return true
+	}
+	for _, u := range users {
+		if u.PasswordHash != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedUsers(s *core.Store) []core.User {
+	users := s.ListUsers()
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Email < users[j].Email
+	})
+	return users
+}
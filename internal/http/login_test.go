@@ -0,0 +1,63 @@
+package http
+
+import (
+	"testing"
+
+	"jwtea/internal/core"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := core.HashPassword("alice-secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	withHash := core.User{Email: "alice@example.com", PasswordHash: hash}
+	withoutHash := core.User{Email: "bob@example.com"}
+
+	tests := []struct {
+		name     string
+		global   string
+		user     core.User
+		password string
+		want     bool
+	}{
+		{"no passwords at all", "", withoutHash, "", true},
+		{"global password", "shared", withoutHash, "shared", true},
+		{"wrong global password", "shared", withoutHash, "nope", false},
+		{"own password", "", withHash, "alice-secret", true},
+		{"own password required", "", withHash, "", false},
+		{"own password wins over global", "shared", withHash, "alice-secret", true},
+		{"global password not accepted for a user with their own", "shared", withHash, "shared", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Login.Password = tt.global
+			if got := checkPassword(deps.Config, tt.user, tt.password); got != tt.want {
+				t.Errorf("checkPassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordRequired(t *testing.T) {
+	tests := []struct {
+		name   string
+		global string
+		users  []core.User
+		want   bool
+	}{
+		{"nobody has a password", "", []core.User{{Email: "a"}}, false},
+		{"global password", "shared", []core.User{{Email: "a"}}, true},
+		{"a user has a password", "", []core.User{{Email: "a"}, {Email: "b", PasswordHash: "x"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Login.Password = tt.global
+			if got := passwordRequired(deps.Config, tt.users); got != tt.want {
+				t.Errorf("passwordRequired = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
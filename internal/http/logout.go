@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/pages"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LogoutHandler handles /logout endpoint (OIDC RP-Initiated Logout)
+type LogoutHandler struct {
+	deps *Dependencies
+}
+
+func NewLogoutHandler(deps *Dependencies) *LogoutHandler {
+	return &LogoutHandler{deps: deps}
+}
+
+func (h *LogoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	clientID := r.Form.Get("client_id")
+	var hintSubject string
+	if hint := r.Form.Get("id_token_hint"); hint != "" {
+		// The hint is usually an expired ID token, so only its signature
+		// counts. It therefore only identifies the session to end and never
+		// logs its subject out on its own.
+		claims, err := core.ParseAndValidateToken(hint, h.deps.Keys, jwt.WithoutClaimsValidation())
+		if err != nil {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "id_token_hint invalid")
+			return
+		}
+		hintSubject, _ = claims["sub"].(string)
+		aud, _ := claims.GetAudience()
+		if clientID == "" && len(aud) > 0 {
+			clientID = aud[0]
+		} else if clientID != "" && !slices.Contains(aud, clientID) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "client_id does not match id_token_hint")
+			return
+		}
+	}
+
+	redirectURI := r.Form.Get("post_logout_redirect_uri")
+	if redirectURI != "" {
+		cl, ok := h.deps.Store.GetClient(clientID)
+		if !ok || !slices.Contains(cl.PostLogoutRedirectURIs, redirectURI) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "post_logout_redirect_uri not registered for the client")
+			return
+		}
+	}
+
+	var userID string
+	if sess, ok := currentSession(h.deps.Store, r); ok && (hintSubject == "" || hintSubject == sess.UserID) {
+		userID = sess.UserID
+		h.deps.Store.DeleteSession(sess.ID)
+		http.SetCookie(w, newSessionCookie(h.deps.Issuer, "", time.Time{}))
+	}
+
+	var frontchannel []string
+	if userID != "" {
+		if h.deps.Config.Logout.RevokeRefreshTokens {
+			h.deps.Store.RevokeRefreshTokensByUser(userID, "")
+		}
+		clients := h.deps.Store.UserClients(userID)
+		h.deps.Store.ForgetUserClients(userID)
+		notifyBackchannelLogout(h.deps, userID, clients)
+		frontchannel = frontchannelLogoutURIs(h.deps, clients)
+	}
+
+	if redirectURI != "" {
+		u, _ := url.Parse(redirectURI)
+		if state := r.Form.Get("state"); state != "" {
+			q := u.Query()
+			q.Set("state", state)
+			u.RawQuery = q.Encode()
+		}
+		redirectURI = u.String()
+		if len(frontchannel) == 0 {
+			http.Redirect(w, r, redirectURI, http.StatusFound)
+			return
+		}
+	}
+
+	pages.RenderLogout(w, http.StatusOK, pages.LogoutData{
+		UserID:           userID,
+		FrontchannelURIs: frontchannel,
+		RedirectURI:      redirectURI,
+	})
+}
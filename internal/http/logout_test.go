@@ -0,0 +1,68 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+func TestLogoutIDTokenHint(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionUser string
+		wantRevoked bool
+	}{
+		{"no session", "", false},
+		{"session of the hint subject", "alice@example.com", true},
+		{"session of another user", "bob@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Logout.RevokeRefreshTokens = true
+			deps.Store.AddClient(core.Client{ID: "web"})
+			deps.Store.SaveRefreshToken(core.RefreshToken{
+				Token:     "alice-rt",
+				ClientID:  "web",
+				UserID:    "alice@example.com",
+				ExpiresAt: time.Now().Add(time.Hour),
+			})
+
+			// An expired ID token is still a valid hint.
+			result, err := core.NewTokenGenerator(deps.Keys, deps.Issuer).Generate(core.TokenRequest{
+				Subject:      "alice@example.com",
+				Audience:     []string{"web"},
+				ClientID:     "web",
+				ExpiresIn:    time.Minute,
+				ChaosExpired: true,
+			})
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/logout?"+url.Values{"id_token_hint": {result.IDToken}}.Encode(), nil)
+			if tt.sessionUser != "" {
+				deps.Store.SaveSession(core.Session{ID: "sess", UserID: tt.sessionUser, AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "sess"})
+			}
+			w := httptest.NewRecorder()
+			NewLogoutHandler(deps).ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+
+			_, live := deps.Store.GetRefreshToken("alice-rt")
+			if live == tt.wantRevoked {
+				t.Errorf("refresh token live = %v, want %v", live, !tt.wantRevoked)
+			}
+			_, sessionLeft := deps.Store.GetSession("sess")
+			if tt.sessionUser != "" && sessionLeft == tt.wantRevoked {
+				t.Errorf("session left = %v, want %v", sessionLeft, !tt.wantRevoked)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+	"jwtea/internal/pki"
+)
+
+// mtlsEndpoints are the endpoints that take client authentication and are
+// therefore also served, under the same paths, on the mTLS listener.
+var mtlsEndpoints = map[string]string{
+	"token_endpoint":                        "/oauth2/token",
+	"pushed_authorization_request_endpoint": "/oauth2/par",
+	"device_authorization_endpoint":         "/oauth2/device_authorization",
+	"userinfo_endpoint":                     "/userinfo",
+	"introspection_endpoint":                "/oauth2/introspect",
+	"revocation_endpoint":                   "/oauth2/revoke",
+}
+
+// mtlsBaseURL is the external URL of the mTLS listener.
+func mtlsBaseURL(cfg *config.Config) string {
+	if cfg.MTLS.BaseURL != "" {
+		return strings.TrimRight(cfg.MTLS.BaseURL, "/")
+	}
+	return fmt.Sprintf("https://%s:%d", cfg.Server.Host, cfg.MTLS.Port)
+}
+
+// mtlsEndpointAliases lists the mTLS URLs of the enabled endpoints for
+// discovery (RFC 8705 section 5).
+func mtlsEndpointAliases(cfg *config.Config) map[string]string {
+	base := mtlsBaseURL(cfg)
+	aliases := map[string]string{}
+	for name, path := range mtlsEndpoints {
+		switch {
+		case name == "introspection_endpoint" && !cfg.Introspection.Enabled:
+		case name == "revocation_endpoint" && !cfg.Revocation.Enabled:
+		default:
+			aliases[name] = base + path
+		}
+	}
+	return aliases
+}
+
+// endpointURL is the URL r was sent to, on whichever listener received it.
+func endpointURL(deps *Dependencies, r *http.Request) string {
+	if r.TLS != nil && deps.Config.MTLS.Enabled {
+		return mtlsBaseURL(deps.Config) + r.URL.Path
+	}
+	return deps.Issuer + r.URL.Path
+}
+
+// clientCertificate returns the certificate the client presented on the mTLS
+// listener, or nil.
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return r.TLS.PeerCertificates[0]
+}
+
+// certThumbprint is the x5t#S256 of the client certificate on r, which
+// tokens issued for r are bound to, or "" without one.
+func certThumbprint(r *http.Request) string {
+	cert := clientCertificate(r)
+	if cert == nil {
+		return ""
+	}
+	return pki.Thumbprint(cert)
+}
+
+// refreshTokenX5T is the certificate a new refresh token for cl is bound to.
+// Like DPoP, RFC 8705 binds the refresh tokens of public clients only.
+func refreshTokenX5T(r *http.Request, cl core.Client) string {
+	if cl.Secret != "" || cl.PublicKey != "" || cl.JWKSURI != "" {
+		return ""
+	}
+	return certThumbprint(r)
+}
+
+// authenticateClientCertificate authenticates cl by the certificate on r
+// (RFC 8705 section 2): self_signed_tls_client_auth when its thumbprint is
+// registered, tls_client_auth when it chains to the local CA and matches the
+// client's subject DN or SAN.
+func authenticateClientCertificate(deps *Dependencies, r *http.Request, cl core.Client) (string, bool) {
+	cert := clientCertificate(r)
+	if cert == nil {
+		return "", false
+	}
+	if slices.Contains(cl.TLSClientCertificateThumbprints, pki.Thumbprint(cert)) {
+		return "self_signed_tls_client_auth", true
+	}
+	if deps.CA == nil {
+		return "", false
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         deps.CA.Pool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return "", false
+	}
+	return "tls_client_auth", certificateMatches(cl, cert)
+}
+
+// certificateMatches checks cert against the first tls_client_auth field
+// set on cl. The subject DN is compared in its RFC 4514 string form.
+func certificateMatches(cl core.Client, cert *x509.Certificate) bool {
+	switch {
+	case cl.TLSClientAuthSubjectDN != "":
+		return cert.Subject.String() == cl.TLSClientAuthSubjectDN
+	case cl.TLSClientAuthSANDNS != "":
+		return slices.Contains(cert.DNSNames, cl.TLSClientAuthSANDNS)
+	case cl.TLSClientAuthSANURI != "":
+		return slices.ContainsFunc(cert.URIs, func(u *url.URL) bool { return u.String() == cl.TLSClientAuthSANURI })
+	case cl.TLSClientAuthSANIP != "":
+		ip := net.ParseIP(cl.TLSClientAuthSANIP)
+		return ip != nil && slices.ContainsFunc(cert.IPAddresses, ip.Equal)
+	case cl.TLSClientAuthSANEmail != "":
+		return slices.Contains(cert.EmailAddresses, cl.TLSClientAuthSANEmail)
+	}
+	return false
+}
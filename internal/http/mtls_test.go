@@ -0,0 +1,146 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"jwtea/internal/core"
+	"jwtea/internal/pki"
+)
+
+func newTestCA(t *testing.T) *pki.CA {
+	t.Helper()
+	ca, err := pki.LoadCA("")
+	if err != nil {
+		t.Fatalf("LoadCA: %v", err)
+	}
+	return ca
+}
+
+func clientCert(t *testing.T, ca *pki.CA, clientID string) *x509.Certificate {
+	t.Helper()
+	cert, _, err := ca.ClientCertificate(clientID)
+	if err != nil {
+		t.Fatalf("ClientCertificate: %v", err)
+	}
+	return cert
+}
+
+// withCert makes r look as if it arrived on the mTLS listener with cert.
+func withCert(r *http.Request, cert *x509.Certificate) *http.Request {
+	if cert != nil {
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	}
+	return r
+}
+
+func TestAuthenticateClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	foreign := newTestCA(t)
+	issued := clientCert(t, ca, "mtls-client")
+	uriCert := clientCert(t, ca, "https://client.example")
+	selfSigned := clientCert(t, foreign, "mtls-client")
+
+	tests := []struct {
+		name       string
+		client     core.Client
+		cert       *x509.Certificate
+		wantMethod string
+		wantOK     bool
+	}{
+		{"subject DN", core.Client{ID: "c", TLSClientAuthSubjectDN: "CN=mtls-client"}, issued, "tls_client_auth", true},
+		{"other subject DN", core.Client{ID: "c", TLSClientAuthSubjectDN: "CN=someone-else"}, issued, "tls_client_auth", false},
+		{"SAN URI", core.Client{ID: "c", TLSClientAuthSANURI: "https://client.example"}, uriCert, "tls_client_auth", true},
+		{"other SAN URI", core.Client{ID: "c", TLSClientAuthSANURI: "https://other.example"}, uriCert, "tls_client_auth", false},
+		{"no tls_client_auth field", core.Client{ID: "c"}, issued, "tls_client_auth", false},
+		{"untrusted CA", core.Client{ID: "c", TLSClientAuthSubjectDN: "CN=mtls-client"}, selfSigned, "", false},
+		{"registered thumbprint", core.Client{ID: "c", TLSClientCertificateThumbprints: []string{pki.Thumbprint(selfSigned)}}, selfSigned, "self_signed_tls_client_auth", true},
+		{"unregistered thumbprint", core.Client{ID: "c", TLSClientCertificateThumbprints: []string{pki.Thumbprint(issued)}}, selfSigned, "", false},
+		{"no certificate", core.Client{ID: "c", TLSClientAuthSubjectDN: "CN=mtls-client"}, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.CA = ca
+			r := withCert(httptest.NewRequest(http.MethodPost, "/oauth2/token", nil), tt.cert)
+			method, ok := authenticateClientCertificate(deps, r, tt.client)
+			if ok != tt.wantOK || method != tt.wantMethod {
+				t.Fatalf("got (%q, %v), want (%q, %v)", method, ok, tt.wantMethod, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCertificateBoundAccessToken(t *testing.T) {
+	ca := newTestCA(t)
+	deps := newTestDeps(t)
+	deps.CA = ca
+	deps.Config.MTLS.Enabled = true
+	deps.Store.AddClient(core.Client{
+		ID:                      "mtls-client",
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  "CN=mtls-client",
+	})
+	deps.Store.AddUser(core.User{Email: "mtls-client"})
+	cert := clientCert(t, ca, "mtls-client")
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"mtls-client"}, "scope": {"openid"}}
+	r := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	NewTokenHandler(deps).ServeHTTP(w, withCert(r, cert))
+	if w.Code != http.StatusOK {
+		t.Fatalf("token: status %d, body %s", w.Code, w.Body)
+	}
+	at, _ := decodeJSON(t, w)["access_token"].(string)
+	cnf, _ := tokenClaims(t, deps, at)["cnf"].(map[string]any)
+	if cnf["x5t#S256"] != pki.Thumbprint(cert) {
+		t.Fatalf("cnf.x5t#S256 = %v, want %s", cnf["x5t#S256"], pki.Thumbprint(cert))
+	}
+
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want int
+	}{
+		{"bound certificate", cert, http.StatusOK},
+		{"another certificate", clientCert(t, ca, "mtls-client"), http.StatusUnauthorized},
+		{"no certificate", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := withCert(httptest.NewRequest(http.MethodGet, "/userinfo", nil), tt.cert)
+			r.Header.Set("Authorization", "Bearer "+at)
+			w := httptest.NewRecorder()
+			NewUserInfoHandler(deps).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestTLSClientAuthRejectsOtherCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	deps := newTestDeps(t)
+	deps.CA = ca
+	deps.Store.AddClient(core.Client{
+		ID:                      "mtls-client",
+		TokenEndpointAuthMethod: "tls_client_auth",
+		TLSClientAuthSubjectDN:  "CN=mtls-client",
+	})
+
+	form := url.Values{"grant_type": {"client_credentials"}, "client_id": {"mtls-client"}}
+	r := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	NewTokenHandler(deps).ServeHTTP(w, withCert(r, clientCert(t, newTestCA(t), "mtls-client")))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want %d, body %s", w.Code, http.StatusUnauthorized, w.Body)
+	}
+}
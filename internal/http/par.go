@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+const requestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// PARHandler handles /oauth2/par endpoint
+type PARHandler struct {
+	deps      *Dependencies
+	authorize *AuthorizeHandler
+}
+
+func NewPARHandler(deps *Dependencies) *PARHandler {
+	return &PARHandler{deps: deps, authorize: NewAuthorizeHandler(deps)}
+}
+
+func (h *PARHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid form")
+		return
+	}
+
+	cl, ok := authenticateClient(h.deps, r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=token")
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	params := r.PostForm
+	if params.Has("request_uri") {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "request_uri is not allowed in a pushed authorization request")
+		return
+	}
+	// Client authentication parameters are not part of the authorization
+	// request; the authenticated client is.
+	for _, p := range []string{"client_secret", "client_assertion", "client_assertion_type"} {
+		params.Del(p)
+	}
+	params.Set("client_id", cl.ID)
+
+	params, signed, aerr := h.authorize.requestObject(params)
+	if aerr != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, aerr.code, aerr.desc)
+		return
+	}
+	if _, _, aerr := h.authorize.parseAuthorizeRequest(params, true, signed); aerr != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, aerr.code, aerr.desc)
+		return
+	}
+
+	id, err := RandCode(32)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "request_uri generation failed")
+		return
+	}
+	expiry := h.deps.Config.PAR.RequestExpiry.Duration
+	pr := core.PushedRequest{
+		RequestURI: requestURIPrefix + id,
+		ClientID:   cl.ID,
+		Params:     params,
+		Signed:     signed,
+		ExpiresAt:  time.Now().Add(expiry),
+	}
+	h.deps.Store.SavePushedRequest(pr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]any{
+		"request_uri": pr.RequestURI,
+		"expires_in":  int(expiry.Seconds()),
+	})
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+// newPARDeps returns dependencies with an auto-login user and the clients
+// "par-client" and "other" (secret "secret").
+func newPARDeps(t *testing.T) *Dependencies {
+	t.Helper()
+	deps := newTestDeps(t)
+	deps.Config.Login.AutoLogin = true
+	deps.Store.AddUser(core.User{Email: "alice@example.com"})
+	for _, id := range []string{"par-client", "other"} {
+		deps.Store.AddClient(core.Client{
+			ID:           id,
+			Secret:       "secret",
+			RedirectURIs: []string{"https://client.example/cb"},
+			SkipConsent:  true,
+		})
+	}
+	return deps
+}
+
+// pushRequest pushes a code request for par-client and returns its
+// request_uri.
+func pushRequest(t *testing.T, deps *Dependencies) string {
+	t.Helper()
+	w := postForm(NewPARHandler(deps), "/oauth2/par", url.Values{
+		"client_id":             {"par-client"},
+		"client_secret":         {"secret"},
+		"response_type":         {"code"},
+		"redirect_uri":          {"https://client.example/cb"},
+		"scope":                 {"openid"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("par: status %d, body %s", w.Code, w.Body)
+	}
+	requestURI, _ := decodeJSON(t, w)["request_uri"].(string)
+	return requestURI
+}
+
+// authorizeByReference sends request_uri to /authorize as clientID.
+func authorizeByReference(deps *Dependencies, clientID, requestURI string) *httptest.ResponseRecorder {
+	q := url.Values{"client_id": {clientID}, "request_uri": {requestURI}}
+	w := httptest.NewRecorder()
+	NewAuthorizeHandler(deps).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil))
+	return w
+}
+
+func TestPushedAuthorizationRequest(t *testing.T) {
+	deps := newPARDeps(t)
+	requestURI := pushRequest(t, deps)
+
+	w := authorizeByReference(deps, "par-client", requestURI)
+	if w.Code != http.StatusFound {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if loc.Query().Get("code") == "" || loc.Query().Get("state") != "xyz" {
+		t.Fatalf("redirect %s carries no code or state", loc)
+	}
+}
+
+func TestPushedAuthorizationRequestUse(t *testing.T) {
+	tests := []struct {
+		name string
+		// clients presenting the request_uri in turn, and the status each gets
+		clients []string
+		want    []int
+		expired bool
+	}{
+		{"used once", []string{"par-client", "par-client"}, []int{http.StatusFound, http.StatusBadRequest}, false},
+		{"another client does not use it up", []string{"other", "", "par-client"}, []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusFound}, false},
+		{"expired", []string{"par-client"}, []int{http.StatusBadRequest}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newPARDeps(t)
+			requestURI := pushRequest(t, deps)
+			if tt.expired {
+				pr, _ := deps.Store.TakePushedRequest(requestURI, "par-client")
+				pr.ExpiresAt = time.Now().Add(-time.Second)
+				deps.Store.SavePushedRequest(pr)
+			}
+			for i, clientID := range tt.clients {
+				w := authorizeByReference(deps, clientID, requestURI)
+				if w.Code != tt.want[i] {
+					t.Fatalf("use %d by %q: status %d, want %d, body %s", i+1, clientID, w.Code, tt.want[i], w.Body)
+				}
+				if w.Code == http.StatusBadRequest && decodeJSON(t, w)["error"] != "invalid_request_uri" {
+					t.Errorf("use %d by %q: %s", i+1, clientID, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestRequirePushedAuthorizationRequests(t *testing.T) {
+	deps := newPARDeps(t)
+	cl, _ := deps.Store.GetClient("par-client")
+	cl.RequirePushedAuthorizationRequests = true
+	deps.Store.UpdateClient(cl)
+
+	q := url.Values{
+		"client_id":             {"par-client"},
+		"response_type":         {"code"},
+		"redirect_uri":          {"https://client.example/cb"},
+		"state":                 {"xyz"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {"S256"},
+	}
+	w := httptest.NewRecorder()
+	NewAuthorizeHandler(deps).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil))
+	loc, _ := url.Parse(w.Header().Get("Location"))
+	if w.Code != http.StatusFound || loc.Query().Get("error") != "invalid_request" {
+		t.Fatalf("plain request: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := authorizeByReference(deps, "par-client", pushRequest(t, deps)); w.Code != http.StatusFound {
+		t.Fatalf("pushed request: status %d, body %s", w.Code, w.Body)
+	}
+}
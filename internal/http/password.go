@@ -0,0 +1,115 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+// handlePassword implements the resource owner password credentials grant.
+// Besides being in oauth.allowed_grant_types it has to be listed in the
+// client's grant_types, and it only works for users with a password.
+func (h *TokenHandler) handlePassword(w http.ResponseWriter, r *http.Request) {
+	cl, ok := authenticateClient(h.deps, r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Basic realm=token")
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+	if !requireGrant(w, h.deps.Config, cl, "password") {
+		return
+	}
+
+	username := r.Form.Get("username")
+	password := r.Form.Get("password")
+	if username == "" || password == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "username and password required")
+		return
+	}
+	user, ok := h.deps.Store.GetUser(username)
+	if !ok || !core.CheckPassword(user.PasswordHash, password) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_grant", "invalid username or password")
+		return
+	}
+
+	scope := r.Form.Get("scope")
+	if scope == "" {
+		scope = strings.Join(h.deps.Config.OAuth.DefaultScopes, " ")
+	}
+	if !requireScope(w, h.deps.Config, cl, scope) {
+		return
+	}
+	resources, ok := requireResources(w, r, h.deps.Store, nil, scope)
+	if !ok {
+		return
+	}
+	details, ok := requireAuthorizationDetails(w, r, h.deps.Config)
+	if !ok {
+		return
+	}
+	authTime := time.Now()
+
+	gen := core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer)
+	req := core.TokenRequest{
+		Subject:               user.Email,
+		Audience:              tokenAudience(h.deps, cl, resources),
+		ClientID:              cl.ID,
+		Scope:                 scope,
+		ExpiresIn:             accessTokenExpiry(h.deps.Config, resources),
+		IDTokenExpiresIn:      h.deps.Config.Tokens.IDTokenExpiry.Duration,
+		AuthTime:              authTime,
+		UserClaims:            userClaims(h.deps.Store, user.Email, scope),
+		AuthorizationDetails:  details,
+		JKT:                   dpopJKT(r),
+		X5T:                   certThumbprint(r),
+		ChaosExpired:          h.deps.Chaos.ConsumeNextTokenExpired(),
+		ChaosInvalidSignature: h.deps.Chaos.IsInvalidSignature(),
+	}
+
+	applyAccessTokenProfile(h.deps, cl, &req)
+	result, err := gen.Generate(req)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "token generation failed")
+		return
+	}
+
+	resp := map[string]any{
+		"access_token": result.AccessToken,
+		"token_type":   accessTokenType(r),
+		"expires_in":   result.ExpiresIn,
+		"scope":        scope,
+	}
+	if HasScope(scope, "openid") {
+		resp["id_token"] = result.IDToken
+	}
+	if len(details) > 0 {
+		resp["authorization_details"] = details
+	}
+	h.deps.Store.TrackUserClient(user.Email, cl.ID)
+
+	if issuesRefreshToken(h.deps.Config, cl, scope) {
+		refreshToken, err := GenerateRefreshToken()
+		if err != nil {
+			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "refresh token generation failed")
+			return
+		}
+		h.deps.Store.SaveRefreshToken(core.RefreshToken{
+			Token:                refreshToken,
+			ClientID:             cl.ID,
+			UserID:               user.Email,
+			Scope:                scope,
+			AuthTime:             authTime,
+			AuthorizationDetails: details,
+			ExpiresAt:            time.Now().Add(h.deps.Config.Tokens.RefreshTokenExpiry.Duration),
+			IssuedAt:             time.Now(),
+			JKT:                  refreshTokenJKT(r, cl),
+			X5T:                  refreshTokenX5T(r, cl),
+		})
+		resp["refresh_token"] = refreshToken
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, resp)
+}
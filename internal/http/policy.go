@@ -0,0 +1,117 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+)
+
+// clientAllowsGrant reports whether grant is allowed globally and for cl.
+// A client without grant_types may use every allowed grant except those in
+// optInGrantTypes, which always have to be listed.
+func clientAllowsGrant(cfg *config.Config, cl core.Client, grant string) bool {
+	if !slices.Contains(cfg.OAuth.AllowedGrantTypes, grant) {
+		return false
+	}
+	if len(cl.GrantTypes) == 0 {
+		return !slices.Contains(optInGrantTypes, grant)
+	}
+	return slices.Contains(cl.GrantTypes, grant)
+}
+
+// optInGrantTypes act on behalf of users without their involvement, so a
+// client only gets them by listing them in grant_types.
+var optInGrantTypes = []string{"password", jwtBearerGrantType, tokenExchangeGrantType}
+
+// requireGrant writes an unauthorized_client error and returns false when cl
+// may not use grant.
+func requireGrant(w http.ResponseWriter, cfg *config.Config, cl core.Client, grant string) bool {
+	if clientAllowsGrant(cfg, cl, grant) {
+		return true
+	}
+	WriteOAuthErrorJSON(w, http.StatusBadRequest, "unauthorized_client", "client may not use the "+grant+" grant")
+	return false
+}
+
+// clientAllowsResponseType reports whether responseType is allowed globally
+// and for cl at /authorize. Clients without response_types may only use
+// "code".
+func clientAllowsResponseType(cfg *config.Config, cl core.Client, responseType string) bool {
+	if !responseTypeAllowed(cfg, responseType) {
+		return false
+	}
+	if len(cl.ResponseTypes) == 0 {
+		return responseType == "code"
+	}
+	return slices.ContainsFunc(cl.ResponseTypes, func(rt string) bool {
+		return normalizeResponseType(rt) == responseType
+	})
+}
+
+// responseTypeAllowed reports whether the normalized responseType is one
+// jwtea implements and is listed in allowed_response_types.
+func responseTypeAllowed(cfg *config.Config, responseType string) bool {
+	values := strings.Fields(responseType)
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		if v != "code" && v != "id_token" && v != "token" {
+			return false
+		}
+	}
+	return slices.ContainsFunc(cfg.OAuth.AllowedResponseTypes, func(rt string) bool {
+		return normalizeResponseType(rt) == responseType
+	})
+}
+
+// normalizeResponseType puts the values of a response_type in a canonical
+// order, so "token id_token" and "id_token token" compare equal.
+func normalizeResponseType(responseType string) string {
+	rank := map[string]int{"code": 0, "id_token": 1, "token": 2}
+	values := strings.Fields(responseType)
+	slices.SortStableFunc(values, func(a, b string) int { return rank[a] - rank[b] })
+	return strings.Join(values, " ")
+}
+
+// hasResponseType reports whether responseType includes value, as in
+// "code" for "code id_token".
+func hasResponseType(responseType, value string) bool {
+	return slices.Contains(strings.Fields(responseType), value)
+}
+
+// scopeAllowed reports whether every scope in scope is supported by the
+// server and, when the client lists allowed_scopes, allowed for cl.
+func scopeAllowed(cfg *config.Config, cl core.Client, scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if len(cfg.OAuth.SupportedScopes) > 0 && !slices.Contains(cfg.OAuth.SupportedScopes, s) {
+			return false
+		}
+		if len(cl.AllowedScopes) > 0 && !slices.Contains(cl.AllowedScopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// requireScope writes an invalid_scope error and returns false when scope
+// is not allowed for cl.
+func requireScope(w http.ResponseWriter, cfg *config.Config, cl core.Client, scope string) bool {
+	if scopeAllowed(cfg, cl, scope) {
+		return true
+	}
+	WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_scope", "requested scope is not allowed")
+	return false
+}
+
+// issuesRefreshToken reports whether a token response for cl should carry a
+// refresh token.
+func issuesRefreshToken(cfg *config.Config, cl core.Client, scope string) bool {
+	if !clientAllowsGrant(cfg, cl, "refresh_token") {
+		return false
+	}
+	return cfg.Tokens.IssueRefreshToken || HasScope(scope, "offline_access")
+}
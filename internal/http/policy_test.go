@@ -0,0 +1,37 @@
+package http
+
+import (
+	"testing"
+
+	"jwtea/internal/core"
+)
+
+func TestClientAllowsGrant(t *testing.T) {
+	tests := []struct {
+		name   string
+		listed []string
+		grant  string
+		want   bool
+	}{
+		{"unlisted client, authorization_code", nil, "authorization_code", true},
+		{"unlisted client, client_credentials", nil, "client_credentials", true},
+		{"unlisted client, password", nil, "password", false},
+		{"unlisted client, jwt-bearer", nil, jwtBearerGrantType, false},
+		{"unlisted client, token exchange", nil, tokenExchangeGrantType, false},
+		{"listed password", []string{"password"}, "password", true},
+		{"listed jwt-bearer", []string{jwtBearerGrantType}, jwtBearerGrantType, true},
+		{"listed token exchange", []string{tokenExchangeGrantType}, tokenExchangeGrantType, true},
+		{"grant not listed", []string{"client_credentials"}, "authorization_code", false},
+		{"grant not allowed globally", []string{"implicit"}, "implicit", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.OAuth.AllowedGrantTypes = append(deps.Config.OAuth.AllowedGrantTypes, "password", jwtBearerGrantType, tokenExchangeGrantType)
+			cl := core.Client{ID: "c", GrantTypes: tt.listed}
+			if got := clientAllowsGrant(deps.Config, cl, tt.grant); got != tt.want {
+				t.Errorf("clientAllowsGrant = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
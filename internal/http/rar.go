@@ -0,0 +1,69 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+
+	"jwtea/internal/config"
+)
+
+const invalidAuthorizationDetails = "invalid_authorization_details"
+
+// parseAuthorizationDetails parses an authorization_details parameter (RFC
+// 9396 section 2): a JSON array of objects, each with a type listed in
+// oauth.authorization_details_types.
+func parseAuthorizationDetails(cfg *config.Config, raw string) ([]map[string]any, error) {
+	var details []map[string]any
+	if err := json.Unmarshal([]byte(raw), &details); err != nil {
+		return nil, errors.New("authorization_details must be a JSON array of objects")
+	}
+	for _, d := range details {
+		typ, _ := d["type"].(string)
+		if typ == "" {
+			return nil, errors.New("authorization_details entry has no type")
+		}
+		if !slices.Contains(cfg.OAuth.AuthorizationDetailsTypes, typ) {
+			return nil, errors.New("unsupported authorization_details type: " + typ)
+		}
+	}
+	return details, nil
+}
+
+// requireAuthorizationDetails parses the authorization_details of a token
+// request. It writes invalid_authorization_details and returns false when
+// they are malformed or of an unsupported type.
+func requireAuthorizationDetails(w http.ResponseWriter, r *http.Request, cfg *config.Config) ([]map[string]any, bool) {
+	raw := r.Form.Get("authorization_details")
+	if raw == "" {
+		return nil, true
+	}
+	details, err := parseAuthorizationDetails(cfg, raw)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, invalidAuthorizationDetails, err.Error())
+		return nil, false
+	}
+	return details, true
+}
+
+// requireGrantedAuthorizationDetails resolves the authorization_details of a
+// token request redeeming a grant. Each must be one of the granted entries
+// (RFC 9396 section 6.1); without the parameter all granted entries apply.
+func requireGrantedAuthorizationDetails(w http.ResponseWriter, r *http.Request, cfg *config.Config, granted []map[string]any) ([]map[string]any, bool) {
+	if r.Form.Get("authorization_details") == "" {
+		return granted, true
+	}
+	requested, ok := requireAuthorizationDetails(w, r, cfg)
+	if !ok {
+		return nil, false
+	}
+	for _, d := range requested {
+		if !slices.ContainsFunc(granted, func(g map[string]any) bool { return reflect.DeepEqual(g, d) }) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, invalidAuthorizationDetails, "authorization_details were not granted")
+			return nil, false
+		}
+	}
+	return requested, true
+}
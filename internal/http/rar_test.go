@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"jwtea/internal/core"
+)
+
+func TestParseAuthorizationDetails(t *testing.T) {
+	tests := []struct {
+		name    string
+		types   []string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{"one entry", []string{"payment"}, `[{"type":"payment","amount":"10"}]`, 1, false},
+		{"several entries", []string{"payment", "account"}, `[{"type":"payment"},{"type":"account"}]`, 2, false},
+		{"empty array", []string{"payment"}, `[]`, 0, false},
+		{"unsupported type", []string{"payment"}, `[{"type":"account"}]`, 0, true},
+		{"no types configured", nil, `[{"type":"payment"}]`, 0, true},
+		{"entry without type", []string{"payment"}, `[{"amount":"10"}]`, 0, true},
+		{"type not a string", []string{"payment"}, `[{"type":1}]`, 0, true},
+		{"object instead of array", []string{"payment"}, `{"type":"payment"}`, 0, true},
+		{"array of strings", []string{"payment"}, `["payment"]`, 0, true},
+		{"not JSON", []string{"payment"}, `type=payment`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.OAuth.AuthorizationDetailsTypes = tt.types
+			got, err := parseAuthorizationDetails(deps.Config, tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(got) != tt.want {
+				t.Errorf("got %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireGrantedAuthorizationDetails(t *testing.T) {
+	granted := []map[string]any{
+		{"type": "payment", "amount": "10"},
+		{"type": "account", "id": "a1"},
+	}
+	tests := []struct {
+		name    string
+		raw     string
+		want    []map[string]any
+		wantErr bool
+	}{
+		{"all granted when absent", "", granted, false},
+		{"granted subset", `[{"type":"account","id":"a1"}]`, granted[1:], false},
+		{"changed entry", `[{"type":"payment","amount":"1000"}]`, nil, true},
+		{"not granted", `[{"type":"payment","amount":"10"},{"type":"account","id":"a2"}]`, nil, true},
+		{"malformed", `[{"amount":"10"}]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.OAuth.AuthorizationDetailsTypes = []string{"payment", "account"}
+			form := url.Values{}
+			if tt.raw != "" {
+				form.Set("authorization_details", tt.raw)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			got, ok := requireGrantedAuthorizationDetails(w, r, deps.Config, granted)
+			if ok == tt.wantErr {
+				t.Fatalf("ok = %v, want %v", ok, !tt.wantErr)
+			}
+			if tt.wantErr {
+				if code := decodeJSON(t, w)["error"]; code != invalidAuthorizationDetails {
+					t.Errorf("error = %v, want %s", code, invalidAuthorizationDetails)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsAuthorizationDetails(t *testing.T) {
+	deps := newTestDeps(t)
+	deps.Config.OAuth.AuthorizationDetailsTypes = []string{"payment"}
+	deps.Store.AddClient(core.Client{ID: "rar-client", Secret: "secret"})
+	h := NewTokenHandler(deps)
+
+	tests := []struct {
+		name     string
+		details  string
+		wantCode int
+	}{
+		{"supported type", `[{"type":"payment","amount":"10"}]`, http.StatusOK},
+		{"unsupported type", `[{"type":"account"}]`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postForm(h, "/oauth2/token", url.Values{
+				"grant_type":            {"client_credentials"},
+				"client_id":             {"rar-client"},
+				"client_secret":         {"secret"},
+				"authorization_details": {tt.details},
+			}, nil)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.wantCode, w.Body)
+			}
+			resp := decodeJSON(t, w)
+			if w.Code != http.StatusOK {
+				if resp["error"] != invalidAuthorizationDetails {
+					t.Errorf("error = %v, want %s", resp["error"], invalidAuthorizationDetails)
+				}
+				return
+			}
+			want := []any{map[string]any{"type": "payment", "amount": "10"}}
+			if !reflect.DeepEqual(resp["authorization_details"], want) {
+				t.Errorf("response authorization_details = %v, want %v", resp["authorization_details"], want)
+			}
+			at, _ := resp["access_token"].(string)
+			if got := tokenClaims(t, deps, at)["authorization_details"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("token authorization_details = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+)
+
+const registrationPath = "/oauth2/register"
+
+// authenticatedGrantTypes need client authentication, so a client registered
+// with token_endpoint_auth_method "none" may not use them.
+var authenticatedGrantTypes = []string{"client_credentials", "password", jwtBearerGrantType, tokenExchangeGrantType}
+
+// delegationGrantTypes let a client obtain tokens for subjects other than
+// itself, so registration only grants them behind an initial access token.
+var delegationGrantTypes = []string{jwtBearerGrantType, tokenExchangeGrantType}
+
+// clientMetadata is the client metadata accepted and returned by dynamic
+// client registration (RFC 7591 section 2).
+type clientMetadata struct {
+	ClientID                string   `json:"client_id,omitempty"`
+	ClientSecret            string   `json:"client_secret,omitempty"`
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	JWKSURI                 string   `json:"jwks_uri,omitempty"`
+	PostLogoutRedirectURIs  []string `json:"post_logout_redirect_uris,omitempty"`
+	BackchannelLogoutURI    string   `json:"backchannel_logout_uri,omitempty"`
+	FrontchannelLogoutURI   string   `json:"frontchannel_logout_uri,omitempty"`
+	RequirePAR              bool     `json:"require_pushed_authorization_requests,omitempty"`
+	RequireSignedRequest    bool     `json:"require_signed_request_object,omitempty"`
+	RequestURIs             []string `json:"request_uris,omitempty"`
+	RequestObjectSigningAlg string   `json:"request_object_signing_alg,omitempty"`
+	TLSSubjectDN            string   `json:"tls_client_auth_subject_dn,omitempty"`
+	TLSSANDNS               string   `json:"tls_client_auth_san_dns,omitempty"`
+	TLSSANURI               string   `json:"tls_client_auth_san_uri,omitempty"`
+	TLSSANIP                string   `json:"tls_client_auth_san_ip,omitempty"`
+	TLSSANEmail             string   `json:"tls_client_auth_san_email,omitempty"`
+	TLSThumbprints          []string `json:"tls_client_certificate_thumbprints,omitempty"`
+	RFC9068AccessTokens     *bool    `json:"rfc9068_access_tokens,omitempty"`
+}
+
+// RegistrationHandler handles /oauth2/register and /oauth2/register/{client_id} endpoints
+type RegistrationHandler struct {
+	deps *Dependencies
+}
+
+func NewRegistrationHandler(deps *Dependencies) *RegistrationHandler {
+	return &RegistrationHandler{deps: deps}
+}
+
+func (h *RegistrationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == registrationPath {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.register(w, r)
+		return
+	}
+
+	clientID := strings.TrimPrefix(r.URL.Path, registrationPath+"/")
+	cl, ok := h.deps.Store.GetClient(clientID)
+	if !ok || cl.RegistrationAccessToken == "" || !bearerMatches(r, cl.RegistrationAccessToken) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="registration"`)
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_token", "registration access token invalid")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.writeClient(w, http.StatusOK, cl)
+	case http.MethodPut:
+		h.update(w, r, cl)
+	case http.MethodDelete:
+		h.deps.Store.DeleteClient(cl.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *RegistrationHandler) register(w http.ResponseWriter, r *http.Request) {
+	if token := h.deps.Config.Registration.InitialAccessToken; token != "" && !bearerMatches(r, token) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="registration"`)
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_token", "initial access token required")
+		return
+	}
+
+	var md clientMetadata
+	if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "request body must be a JSON object")
+		return
+	}
+
+	clientID, err := RandCode(16)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "client id generation failed")
+		return
+	}
+	cl := core.Client{ID: clientID}
+	if !h.applyMetadata(w, &cl, md) {
+		return
+	}
+	switch cl.TokenEndpointAuthMethod {
+	case "none", "private_key_jwt", "tls_client_auth", "self_signed_tls_client_auth":
+	default:
+		if cl.Secret, err = RandCode(32); err != nil {
+			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "client secret generation failed")
+			return
+		}
+	}
+	if cl.RegistrationAccessToken, err = RandCode(32); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "registration token generation failed")
+		return
+	}
+
+	h.deps.Store.AddClient(cl)
+	h.writeClient(w, http.StatusCreated, cl)
+}
+
+// update replaces the client's metadata (RFC 7592 section 2.2). The client
+// ID, secret and registration access token stay as they were.
+func (h *RegistrationHandler) update(w http.ResponseWriter, r *http.Request, cl core.Client) {
+	var md clientMetadata
+	if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "request body must be a JSON object")
+		return
+	}
+	if md.ClientID != cl.ID {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "client_id does not match")
+		return
+	}
+	if md.ClientSecret != "" && subtle.ConstantTimeCompare([]byte(md.ClientSecret), []byte(cl.Secret)) != 1 {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "client_secret does not match")
+		return
+	}
+
+	updated := core.Client{
+		ID:                      cl.ID,
+		Secret:                  cl.Secret,
+		SkipConsent:             cl.SkipConsent,
+		TokenExchangeAudiences:  cl.TokenExchangeAudiences,
+		PublicKey:               cl.PublicKey,
+		RegistrationAccessToken: cl.RegistrationAccessToken,
+	}
+	if !h.applyMetadata(w, &updated, md) {
+		return
+	}
+	if !h.deps.Store.UpdateClient(updated) {
+		WriteOAuthErrorJSON(w, http.StatusUnauthorized, "invalid_token", "client no longer exists")
+		return
+	}
+	h.writeClient(w, http.StatusOK, updated)
+}
+
+// applyMetadata validates md and copies it onto cl, filling in the RFC 7591
+// defaults. It writes the error response itself and returns false when the
+// metadata is unacceptable.
+func (h *RegistrationHandler) applyMetadata(w http.ResponseWriter, cl *core.Client, md clientMetadata) bool {
+	cfg := h.deps.Config
+
+	grantTypes := md.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{"authorization_code"}
+	}
+	for _, grant := range grantTypes {
+		if !slices.Contains(cfg.OAuth.AllowedGrantTypes, grant) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "grant type not allowed: "+grant)
+			return false
+		}
+	}
+
+	var responseTypes []string
+	for _, rt := range md.ResponseTypes {
+		if !responseTypeAllowed(cfg, normalizeResponseType(rt)) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported response type: "+rt)
+			return false
+		}
+		responseTypes = append(responseTypes, normalizeResponseType(rt))
+	}
+	if len(responseTypes) == 0 {
+		responseTypes = []string{"code"}
+	}
+
+	if slices.Contains(grantTypes, "authorization_code") && len(md.RedirectURIs) == 0 {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_redirect_uri", "redirect_uris required for the authorization_code grant")
+		return false
+	}
+	for _, uri := range append(slices.Clone(md.RedirectURIs), md.PostLogoutRedirectURIs...) {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_redirect_uri", "invalid redirect URI: "+uri)
+			return false
+		}
+	}
+
+	for _, uri := range md.RequestURIs {
+		if u, err := url.Parse(uri); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "request_uris must be http(s) URLs: "+uri)
+			return false
+		}
+	}
+	if alg := md.RequestObjectSigningAlg; alg != "" && alg != "none" && !slices.Contains(keys.SupportedAlgorithms(), alg) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported request_object_signing_alg: "+alg)
+		return false
+	}
+
+	scopes := strings.Fields(md.Scope)
+	for _, s := range scopes {
+		if len(cfg.OAuth.SupportedScopes) > 0 && !slices.Contains(cfg.OAuth.SupportedScopes, s) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported scope: "+s)
+			return false
+		}
+	}
+
+	method := md.TokenEndpointAuthMethod
+	if method == "" {
+		method = "client_secret_basic"
+	}
+	switch method {
+	case "client_secret_basic", "client_secret_post", "client_secret_jwt", "none":
+	case "private_key_jwt":
+		if md.JWKSURI == "" && cl.PublicKey == "" {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "private_key_jwt requires jwks_uri")
+			return false
+		}
+	case "tls_client_auth":
+		if md.TLSSubjectDN == "" && md.TLSSANDNS == "" && md.TLSSANURI == "" && md.TLSSANIP == "" && md.TLSSANEmail == "" {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "tls_client_auth requires a tls_client_auth_subject_dn or SAN")
+			return false
+		}
+	case "self_signed_tls_client_auth":
+		if len(md.TLSThumbprints) == 0 {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "self_signed_tls_client_auth requires tls_client_certificate_thumbprints")
+			return false
+		}
+	default:
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "unsupported token_endpoint_auth_method: "+method)
+		return false
+	}
+	for _, grant := range grantTypes {
+		if method == "none" && slices.Contains(authenticatedGrantTypes, grant) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "grant type requires client authentication: "+grant)
+			return false
+		}
+		if cfg.Registration.InitialAccessToken == "" && slices.Contains(delegationGrantTypes, grant) {
+			WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_client_metadata", "grant type requires an initial access token: "+grant)
+			return false
+		}
+	}
+
+	cl.RedirectURIs = md.RedirectURIs
+	cl.GrantTypes = grantTypes
+	cl.ResponseTypes = responseTypes
+	cl.AllowedScopes = scopes
+	cl.TokenEndpointAuthMethod = method
+	cl.JWKSURI = md.JWKSURI
+	cl.PostLogoutRedirectURIs = md.PostLogoutRedirectURIs
+	cl.BackchannelLogoutURI = md.BackchannelLogoutURI
+	cl.FrontchannelLogoutURI = md.FrontchannelLogoutURI
+	cl.RequirePushedAuthorizationRequests = md.RequirePAR
+	cl.RequireSignedRequestObject = md.RequireSignedRequest
+	cl.RequestURIs = md.RequestURIs
+	cl.RequestObjectSigningAlg = md.RequestObjectSigningAlg
+	cl.TLSClientAuthSubjectDN = md.TLSSubjectDN
+	cl.TLSClientAuthSANDNS = md.TLSSANDNS
+	cl.TLSClientAuthSANURI = md.TLSSANURI
+	cl.TLSClientAuthSANIP = md.TLSSANIP
+	cl.TLSClientAuthSANEmail = md.TLSSANEmail
+	cl.TLSClientCertificateThumbprints = md.TLSThumbprints
+	cl.RFC9068AccessTokens = md.RFC9068AccessTokens
+	return true
+}
+
+func (h *RegistrationHandler) writeClient(w http.ResponseWriter, status int, cl core.Client) {
+	resp := map[string]any{
+		"client_id":                  cl.ID,
+		"registration_access_token":  cl.RegistrationAccessToken,
+		"registration_client_uri":    h.deps.Issuer + registrationPath + "/" + url.PathEscape(cl.ID),
+		"redirect_uris":              cl.RedirectURIs,
+		"grant_types":                cl.GrantTypes,
+		"response_types":             cl.ResponseTypes,
+		"token_endpoint_auth_method": cl.TokenEndpointAuthMethod,
+	}
+	if status == http.StatusCreated {
+		resp["client_id_issued_at"] = time.Now().Unix()
+	}
+	if cl.Secret != "" {
+		resp["client_secret"] = cl.Secret
+		resp["client_secret_expires_at"] = 0
+	}
+	if len(cl.AllowedScopes) > 0 {
+		resp["scope"] = strings.Join(cl.AllowedScopes, " ")
+	}
+	if cl.JWKSURI != "" {
+		resp["jwks_uri"] = cl.JWKSURI
+	}
+	if len(cl.PostLogoutRedirectURIs) > 0 {
+		resp["post_logout_redirect_uris"] = cl.PostLogoutRedirectURIs
+	}
+	if cl.BackchannelLogoutURI != "" {
+		resp["backchannel_logout_uri"] = cl.BackchannelLogoutURI
+	}
+	if cl.FrontchannelLogoutURI != "" {
+		resp["frontchannel_logout_uri"] = cl.FrontchannelLogoutURI
+	}
+	if cl.RequirePushedAuthorizationRequests {
+		resp["require_pushed_authorization_requests"] = true
+	}
+	if cl.RequireSignedRequestObject {
+		resp["require_signed_request_object"] = true
+	}
+	if len(cl.RequestURIs) > 0 {
+		resp["request_uris"] = cl.RequestURIs
+	}
+	if cl.RequestObjectSigningAlg != "" {
+		resp["request_object_signing_alg"] = cl.RequestObjectSigningAlg
+	}
+	for name, value := range map[string]string{
+		"tls_client_auth_subject_dn": cl.TLSClientAuthSubjectDN,
+		"tls_client_auth_san_dns":    cl.TLSClientAuthSANDNS,
+		"tls_client_auth_san_uri":    cl.TLSClientAuthSANURI,
+		"tls_client_auth_san_ip":     cl.TLSClientAuthSANIP,
+		"tls_client_auth_san_email":  cl.TLSClientAuthSANEmail,
+	} {
+		if value != "" {
+			resp[name] = value
+		}
+	}
+	if len(cl.TLSClientCertificateThumbprints) > 0 {
+		resp["tls_client_certificate_thumbprints"] = cl.TLSClientCertificateThumbprints
+	}
+	if cl.RFC9068AccessTokens != nil {
+		resp["rfc9068_access_tokens"] = *cl.RFC9068AccessTokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	writeJSON(w, resp)
+}
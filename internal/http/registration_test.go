@@ -0,0 +1,135 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func sendJSON(h http.Handler, method, path, bearer string, body any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	r := httptest.NewRequest(method, path, strings.NewReader(string(data)))
+	r.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestRegistrationGrantRestrictions(t *testing.T) {
+	tests := []struct {
+		name    string
+		iat     string
+		method  string
+		grants  []string
+		jwksURI string
+		want    int
+	}{
+		{"public client, code", "", "none", []string{"authorization_code"}, "", http.StatusOK},
+		{"public client, client_credentials", "", "none", []string{"client_credentials"}, "", http.StatusBadRequest},
+		{"public client, password", "", "none", []string{"authorization_code", "password"}, "", http.StatusBadRequest},
+		{"public client, jwt-bearer", "iat", "none", []string{jwtBearerGrantType}, "", http.StatusBadRequest},
+		{"public client, token exchange", "iat", "none", []string{tokenExchangeGrantType}, "", http.StatusBadRequest},
+		{"confidential client, client_credentials", "", "client_secret_basic", []string{"client_credentials"}, "", http.StatusOK},
+		{"jwt-bearer without initial access token", "", "private_key_jwt", []string{jwtBearerGrantType}, "https://rp.example.com/jwks", http.StatusBadRequest},
+		{"jwt-bearer with initial access token", "iat", "private_key_jwt", []string{jwtBearerGrantType}, "https://rp.example.com/jwks", http.StatusOK},
+		{"token exchange without initial access token", "", "client_secret_basic", []string{tokenExchangeGrantType}, "", http.StatusBadRequest},
+		{"token exchange with initial access token", "iat", "client_secret_basic", []string{tokenExchangeGrantType}, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		md := map[string]any{
+			"redirect_uris":              []string{"https://rp.example.com/cb"},
+			"grant_types":                tt.grants,
+			"token_endpoint_auth_method": tt.method,
+			"jwks_uri":                   tt.jwksURI,
+		}
+
+		t.Run(tt.name+": register", func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.OAuth.AllowedGrantTypes = append(deps.Config.OAuth.AllowedGrantTypes, "password", jwtBearerGrantType, tokenExchangeGrantType)
+			deps.Config.Registration.InitialAccessToken = tt.iat
+
+			w := sendJSON(NewRegistrationHandler(deps), http.MethodPost, registrationPath, tt.iat, md)
+			want := tt.want
+			if want == http.StatusOK {
+				want = http.StatusCreated
+			}
+			if w.Code != want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, want, w.Body)
+			}
+			if w.Code == http.StatusCreated {
+				id, _ := decodeJSON(t, w)["client_id"].(string)
+				if _, ok := deps.Store.GetClient(id); !ok {
+					t.Errorf("client %q not stored", id)
+				}
+			}
+		})
+
+		t.Run(tt.name+": update", func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.OAuth.AllowedGrantTypes = append(deps.Config.OAuth.AllowedGrantTypes, "password", jwtBearerGrantType, tokenExchangeGrantType)
+			deps.Config.Registration.InitialAccessToken = tt.iat
+			h := NewRegistrationHandler(deps)
+
+			w := sendJSON(h, http.MethodPost, registrationPath, tt.iat, map[string]any{
+				"redirect_uris": []string{"https://rp.example.com/cb"},
+			})
+			if w.Code != http.StatusCreated {
+				t.Fatalf("register: status %d, body %s", w.Code, w.Body)
+			}
+			created := decodeJSON(t, w)
+			id, _ := created["client_id"].(string)
+			rat, _ := created["registration_access_token"].(string)
+
+			md["client_id"] = id
+			w = sendJSON(h, http.MethodPut, registrationPath+"/"+id, rat, md)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			cl, _ := deps.Store.GetClient(id)
+			if updated := slices.Equal(cl.GrantTypes, tt.grants); updated != (tt.want == http.StatusOK) {
+				t.Errorf("stored grant types %v after status %d", cl.GrantTypes, w.Code)
+			}
+		})
+	}
+}
+
+func TestRegistrationRequestObjectMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		requestURIs []string
+		alg         string
+		want        int
+	}{
+		{"request_uris", []string{"https://rp.example.com/request.jwt"}, "", http.StatusCreated},
+		{"non-http request_uri", []string{"file:///etc/passwd"}, "", http.StatusBadRequest},
+		{"none", nil, "none", http.StatusCreated},
+		{"supported algorithm", nil, "ES256", http.StatusCreated},
+		{"unsupported algorithm", nil, "XS256", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			w := sendJSON(NewRegistrationHandler(deps), http.MethodPost, registrationPath, "", map[string]any{
+				"redirect_uris":              []string{"https://rp.example.com/cb"},
+				"request_uris":               tt.requestURIs,
+				"request_object_signing_alg": tt.alg,
+			})
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			cl, _ := deps.Store.GetClient(decodeJSON(t, w)["client_id"].(string))
+			if !slices.Equal(cl.RequestURIs, tt.requestURIs) || cl.RequestObjectSigningAlg != tt.alg {
+				t.Errorf("stored request_uris %v, request_object_signing_alg %q", cl.RequestURIs, cl.RequestObjectSigningAlg)
+			}
+		})
+	}
+}
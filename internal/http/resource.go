@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+)
+
+// targetResources looks up the resource indicators of a request (RFC 8707)
+// in the resource registry. It returns false when one is not registered.
+func targetResources(store *core.Store, identifiers []string) ([]core.Resource, bool) {
+	var resources []core.Resource
+	for _, id := range identifiers {
+		if slices.ContainsFunc(resources, func(r core.Resource) bool { return r.Identifier == id }) {
+			continue
+		}
+		r, ok := store.GetResource(id)
+		if !ok {
+			return nil, false
+		}
+		resources = append(resources, r)
+	}
+	return resources, true
+}
+
+// resourcesOfferScope reports whether every scope value is offered by one of
+// resources. OpenID Connect scopes are the issuer's own and always allowed.
+func resourcesOfferScope(resources []core.Resource, scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == "openid" || s == "offline_access" {
+			continue
+		}
+		if _, ok := core.ScopeClaims[s]; ok {
+			continue
+		}
+		if !slices.ContainsFunc(resources, func(r core.Resource) bool {
+			return len(r.Scopes) == 0 || slices.Contains(r.Scopes, s)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// requireResources resolves the resource parameters of a token request,
+// which must be registered and, when granted is not nil, among the resources
+// granted earlier. Without resource parameters the granted resources apply.
+// It writes invalid_target or invalid_scope and returns false otherwise.
+func requireResources(w http.ResponseWriter, r *http.Request, store *core.Store, granted []string, scope string) ([]core.Resource, bool) {
+	requested := r.Form["resource"]
+	if len(requested) == 0 {
+		requested = granted
+	} else if granted != nil {
+		for _, id := range requested {
+			if !slices.Contains(granted, id) {
+				WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_target", "resource was not granted: "+id)
+				return nil, false
+			}
+		}
+	}
+	resources, ok := targetResources(store, requested)
+	if !ok {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_target", "unknown resource")
+		return nil, false
+	}
+	if len(resources) > 0 && !resourcesOfferScope(resources, scope) {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_scope", "requested scope is not offered by the resource")
+		return nil, false
+	}
+	return resources, true
+}
+
+// resourceIdentifiers returns the identifiers of resources, or nil.
+func resourceIdentifiers(resources []core.Resource) []string {
+	var ids []string
+	for _, r := range resources {
+		ids = append(ids, r.Identifier)
+	}
+	return ids
+}
+
+// tokenAudience is the aud of an access token for cl: the targeted
+// resources or, when there are none, the default resource of RFC 9068
+// access tokens or else the client itself.
+func tokenAudience(deps *Dependencies, cl core.Client, resources []core.Resource) []string {
+	switch {
+	case len(resources) > 0:
+		return resourceIdentifiers(resources)
+	case rfc9068Enabled(deps.Config, cl):
+		return []string{defaultResource(deps)}
+	}
+	return []string{cl.ID}
+}
+
+// accessTokenExpiry is the lifetime of an access token for resources: the
+// shortest resource lifetime, or tokens.access_token_expiry.
+func accessTokenExpiry(cfg *config.Config, resources []core.Resource) time.Duration {
+	expiry := time.Duration(0)
+	for _, r := range resources {
+		if r.AccessTokenExpiry > 0 && (expiry == 0 || r.AccessTokenExpiry < expiry) {
+			expiry = r.AccessTokenExpiry
+		}
+	}
+	if expiry == 0 {
+		return cfg.Tokens.AccessTokenExpiry.Duration
+	}
+	return expiry
+}
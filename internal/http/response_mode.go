@@ -0,0 +1,125 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/pages"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// responseModes are the supported response_mode values. The .jwt variants
+// (and plain "jwt", the response type's default mode) are JARM: the response
+// parameters are sent as one signed JWT in a "response" parameter.
+var responseModes = []string{"query", "fragment", "form_post", "jwt", "query.jwt", "fragment.jwt", "form_post.jwt"}
+
+// jarmExpiry is how long a JARM response JWT is valid.
+const jarmExpiry = 5 * time.Minute
+
+// responseMode resolves how the response to ar is delivered: the base mode
+// (query, fragment or form_post) and whether it is wrapped in a JWT.
+func responseMode(ar core.AuthRequest) (string, bool) {
+	switch ar.ResponseMode {
+	case "":
+		return defaultResponseMode(ar.ResponseType), false
+	case "jwt":
+		return defaultResponseMode(ar.ResponseType), true
+	}
+	return strings.CutSuffix(ar.ResponseMode, ".jwt")
+}
+
+// defaultResponseMode is query for the code response type and fragment for
+// every response type that returns tokens from /authorize.
+func defaultResponseMode(responseType string) string {
+	if responseType == "" || responseType == "code" {
+		return "query"
+	}
+	return "fragment"
+}
+
+// respond delivers authorization response params to ar's redirect URI in
+// the requested response mode.
+func (h *AuthorizeHandler) respond(w http.ResponseWriter, r *http.Request, ar core.AuthRequest, params url.Values) {
+	u, err := url.Parse(ar.RedirectURI)
+	if err != nil {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, "invalid_request", "invalid redirect_uri")
+		return
+	}
+
+	mode, isJWT := responseMode(ar)
+	if isJWT {
+		response, err := h.signResponse(ar, params)
+		if err != nil {
+			WriteOAuthErrorJSON(w, http.StatusInternalServerError, "server_error", "response signing failed")
+			return
+		}
+		params = url.Values{"response": {response}}
+	}
+
+	switch mode {
+	case "fragment":
+		u.Fragment = ""
+		http.Redirect(w, r, u.String()+"#"+params.Encode(), http.StatusFound)
+	case "form_post":
+		data := pages.FormPostData{RedirectURI: ar.RedirectURI}
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data.Params = append(data.Params, pages.FormParam{Name: name, Value: params.Get(name)})
+		}
+		pages.RenderFormPost(w, data)
+	default:
+		q := u.Query()
+		for name, values := range params {
+			q[name] = values
+		}
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusFound)
+	}
+}
+
+// signResponse wraps authorization response params in a JARM JWT for the
+// client.
+func (h *AuthorizeHandler) signResponse(ar core.AuthRequest, params url.Values) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": h.deps.Issuer,
+		"aud": ar.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(jarmExpiry).Unix(),
+	}
+	for name := range params {
+		claims[name] = params.Get(name)
+	}
+	return core.NewTokenGenerator(h.deps.Keys, h.deps.Issuer).Sign(claims, "")
+}
+
+// errorRedirect returns an OAuth error to ar's redirect URI in the requested
+// response mode, or as JSON when there is no redirect URI to return it to.
+func (h *AuthorizeHandler) errorRedirect(w http.ResponseWriter, r *http.Request, ar core.AuthRequest, code, desc string) {
+	if ar.RedirectURI == "" {
+		WriteOAuthErrorJSON(w, http.StatusBadRequest, code, desc)
+		return
+	}
+	params := url.Values{"error": {code}}
+	if desc != "" {
+		params.Set("error_description", desc)
+	}
+	if ar.State != "" {
+		params.Set("state", ar.State)
+	}
+	h.respond(w, r, ar, params)
+}
+
+func validResponseMode(mode string) bool {
+	return mode == "" || slices.Contains(responseModes, mode)
+}
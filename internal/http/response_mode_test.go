@@ -0,0 +1,248 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+func TestRespondJARM(t *testing.T) {
+	deps := newTestDeps(t)
+	h := NewAuthorizeHandler(deps)
+	formResponse := regexp.MustCompile(`name="response" value="([^"]+)"`)
+
+	tests := []struct {
+		name         string
+		responseType string
+		responseMode string
+		// where the response parameter is found: query, fragment or form
+		want string
+	}{
+		{"query.jwt", "code", "query.jwt", "query"},
+		{"fragment.jwt", "code", "fragment.jwt", "fragment"},
+		{"form_post.jwt", "code", "form_post.jwt", "form"},
+		{"jwt for code", "code", "jwt", "query"},
+		{"jwt for tokens", "code id_token", "jwt", "fragment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := core.AuthRequest{
+				ClientID:     "jarm-client",
+				RedirectURI:  "https://client.example/cb",
+				ResponseType: tt.responseType,
+				ResponseMode: tt.responseMode,
+			}
+			w := httptest.NewRecorder()
+			h.respond(w, httptest.NewRequest(http.MethodGet, "/authorize", nil), ar, url.Values{"code": {"the-code"}, "state": {"xyz"}})
+
+			var response string
+			switch tt.want {
+			case "form":
+				m := formResponse.FindStringSubmatch(w.Body.String())
+				if m == nil {
+					t.Fatalf("form has no response field: %s", w.Body)
+				}
+				response = m[1]
+			default:
+				loc, err := url.Parse(w.Header().Get("Location"))
+				if err != nil || w.Code != http.StatusFound {
+					t.Fatalf("status %d, Location %q", w.Code, w.Header().Get("Location"))
+				}
+				params := loc.Query()
+				if tt.want == "fragment" {
+					params, _ = url.ParseQuery(loc.Fragment)
+				}
+				if params.Has("code") || params.Has("state") {
+					t.Errorf("response parameters sent in the clear: %s", loc)
+				}
+				response = params.Get("response")
+			}
+
+			claims := tokenClaims(t, deps, response)
+			for name, want := range map[string]any{"iss": testIssuer, "aud": "jarm-client", "code": "the-code", "state": "xyz"} {
+				if claims[name] != want {
+					t.Errorf("%s = %v, want %v", name, claims[name], want)
+				}
+			}
+			if _, ok := claims["exp"]; !ok {
+				t.Error("response JWT has no exp")
+			}
+		})
+	}
+}
+
+func TestResponseModeForTokens(t *testing.T) {
+	tests := []struct {
+		responseType string
+		responseMode string
+		wantErr      bool
+	}{
+		{"code", "query", false},
+		{"code", "query.jwt", false},
+		{"token", "", false},
+		{"token", "fragment.jwt", false},
+		{"token", "form_post.jwt", false},
+		{"token", "jwt", false},
+		{"token", "query", true},
+		{"token", "query.jwt", true},
+		{"code id_token", "query", true},
+		{"code id_token", "query.jwt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.responseType+" "+tt.responseMode, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddClient(core.Client{
+				ID:            "client",
+				RedirectURIs:  []string{"https://client.example/cb"},
+				ResponseTypes: []string{tt.responseType},
+			})
+			q := url.Values{
+				"client_id":             {"client"},
+				"redirect_uri":          {"https://client.example/cb"},
+				"response_type":         {tt.responseType},
+				"scope":                 {"openid"},
+				"nonce":                 {"n"},
+				"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+				"code_challenge_method": {"S256"},
+			}
+			if tt.responseMode != "" {
+				q.Set("response_mode", tt.responseMode)
+			}
+			_, _, aerr := NewAuthorizeHandler(deps).parseAuthorizeRequest(q, false, false)
+			if tt.wantErr {
+				if aerr == nil || aerr.code != "invalid_request" {
+					t.Fatalf("error = %+v, want invalid_request", aerr)
+				}
+				return
+			}
+			if aerr != nil {
+				t.Fatalf("unexpected error %s: %s", aerr.code, aerr.desc)
+			}
+		})
+	}
+}
+
+func TestAuthorizeErrorsBeforeRedirectCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"unregistered redirect_uri", url.Values{"client_id": {"web"}, "redirect_uri": {"https://attacker.example/cb"}}},
+		{"unknown client", url.Values{"client_id": {"nobody"}, "redirect_uri": {"https://client.example/cb"}}},
+		{"invalid redirect_uri", url.Values{"client_id": {"web"}, "redirect_uri": {"not a uri"}}},
+		{"missing response_type", url.Values{"client_id": {"web"}, "redirect_uri": {"https://attacker.example/cb"}, "response_type": {""}}},
+	}
+	for _, mode := range []string{"query", "query.jwt", "fragment.jwt", "form_post.jwt"} {
+		for _, tt := range tests {
+			t.Run(mode+": "+tt.name, func(t *testing.T) {
+				deps := newTestDeps(t)
+				deps.Store.AddClient(core.Client{ID: "web", RedirectURIs: []string{"https://client.example/cb"}})
+				q := url.Values{"response_type": {"code"}, "state": {"xyz"}, "response_mode": {mode}}
+				for k, v := range tt.query {
+					q[k] = v
+				}
+
+				w := httptest.NewRecorder()
+				NewAuthorizeHandler(deps).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil))
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("status %d, want 400", w.Code)
+				}
+				if loc := w.Header().Get("Location"); loc != "" {
+					t.Errorf("redirected to %s", loc)
+				}
+				if resp := decodeJSON(t, w); resp["error"] == nil || resp["response"] != nil {
+					t.Errorf("unexpected error response %v", resp)
+				}
+			})
+		}
+	}
+}
+
+func TestImplicitAndHybridFragment(t *testing.T) {
+	tests := []struct {
+		responseType string
+		want         []string
+	}{
+		{"token", []string{"access_token", "token_type", "expires_in", "scope"}},
+		{"id_token", []string{"id_token"}},
+		{"id_token token", []string{"access_token", "token_type", "expires_in", "scope", "id_token"}},
+		{"code id_token", []string{"code", "id_token"}},
+		{"code token", []string{"code", "access_token", "token_type", "expires_in", "scope"}},
+		{"code id_token token", []string{"code", "access_token", "token_type", "expires_in", "scope", "id_token"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.responseType, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Login.SkipConsent = true
+			deps.Store.AddUser(core.User{Email: "alice@example.com"})
+			deps.Store.AddClient(core.Client{
+				ID:            "spa",
+				RedirectURIs:  []string{"https://client.example/cb"},
+				ResponseTypes: []string{tt.responseType},
+			})
+			deps.Store.SaveSession(core.Session{ID: "sess", UserID: "alice@example.com", AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+
+			q := url.Values{
+				"client_id":     {"spa"},
+				"redirect_uri":  {"https://client.example/cb"},
+				"response_type": {tt.responseType},
+				"scope":         {"openid"},
+				"state":         {"xyz"},
+				"nonce":         {"n-0S6"},
+			}
+			r := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+			r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "sess"})
+			w := httptest.NewRecorder()
+			NewAuthorizeHandler(deps).ServeHTTP(w, r)
+			if w.Code != http.StatusFound {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+
+			loc, _ := url.Parse(w.Header().Get("Location"))
+			if loc.RawQuery != "" {
+				t.Errorf("response parameters in the query: %s", loc)
+			}
+			params, _ := url.ParseQuery(loc.Fragment)
+			if params.Get("state") != "xyz" {
+				t.Errorf("state = %q, want xyz", params.Get("state"))
+			}
+			params.Del("state")
+			if len(params) != len(tt.want) {
+				t.Errorf("fragment %v, want %v", params, tt.want)
+			}
+			for _, name := range tt.want {
+				if !params.Has(name) {
+					t.Errorf("%s missing from the fragment", name)
+				}
+			}
+			if params.Has("token_type") && params.Get("token_type") != "Bearer" {
+				t.Errorf("token_type = %q, want Bearer", params.Get("token_type"))
+			}
+
+			if !params.Has("id_token") {
+				return
+			}
+			claims := tokenClaims(t, deps, params.Get("id_token"))
+			if claims["nonce"] != "n-0S6" {
+				t.Errorf("nonce = %v, want n-0S6", claims["nonce"])
+			}
+			for claim, value := range map[string]string{"at_hash": params.Get("access_token"), "c_hash": params.Get("code")} {
+				got, present := claims[claim]
+				if value == "" {
+					if present {
+						t.Errorf("%s = %v without the value it would hash", claim, got)
+					}
+					continue
+				}
+				if want, _ := core.TokenHash(value, "RS256"); got != want {
+					t.Errorf("%s = %v, want %s", claim, got, want)
+				}
+			}
+		})
+	}
+}
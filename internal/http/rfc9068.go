@@ -0,0 +1,39 @@
+package http
+
+import (
+	"jwtea/internal/config"
+	"jwtea/internal/core"
+)
+
+// rfc9068Enabled reports whether cl gets RFC 9068 access tokens: its
+// rfc9068_access_tokens setting, or tokens.rfc9068.enabled.
+func rfc9068Enabled(cfg *config.Config, cl core.Client) bool {
+	if cl.RFC9068AccessTokens != nil {
+		return *cl.RFC9068AccessTokens
+	}
+	return cfg.Tokens.RFC9068.Enabled
+}
+
+// defaultResource is the aud of RFC 9068 access tokens requested without a
+// resource indicator (RFC 9068 section 3).
+func defaultResource(deps *Dependencies) string {
+	if deps.Config.Tokens.RFC9068.DefaultResource != "" {
+		return deps.Config.Tokens.RFC9068.DefaultResource
+	}
+	return deps.Issuer
+}
+
+// applyAccessTokenProfile fills in what the RFC 9068 profile adds to an
+// access token for cl, when cl uses it. The roles, groups and entitlements
+// come from the user req.Subject names, if any.
+func applyAccessTokenProfile(deps *Dependencies, cl core.Client, req *core.TokenRequest) {
+	if !rfc9068Enabled(deps.Config, cl) {
+		return
+	}
+	req.RFC9068 = true
+	req.ClientID = cl.ID
+	req.ACR = deps.Config.Tokens.RFC9068.ACR
+	if u, ok := deps.Store.GetUser(req.Subject); ok {
+		req.UserAttributes = core.AccessTokenUserClaims(u)
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"jwtea/internal/core"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAccessTokenProfile(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name            string
+		enabled         bool
+		defaultResource string
+		override        *bool
+		wantTyp         string
+		wantAud         string
+	}{
+		{"disabled", false, "", nil, "JWT", "svc"},
+		{"enabled", true, "", nil, "at+jwt", testIssuer},
+		{"enabled with default resource", true, "https://api.example.com", nil, "at+jwt", "https://api.example.com"},
+		{"enabled for the client", false, "", &on, "at+jwt", testIssuer},
+		{"disabled for the client", true, "", &off, "JWT", "svc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Tokens.RFC9068.Enabled = tt.enabled
+			deps.Config.Tokens.RFC9068.DefaultResource = tt.defaultResource
+			deps.Store.AddClient(core.Client{ID: "svc", Secret: "secret", RFC9068AccessTokens: tt.override})
+
+			w := postForm(NewTokenHandler(deps), "/oauth2/token", url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {"svc"},
+				"client_secret": {"secret"},
+			}, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			at, _ := decodeJSON(t, w)["access_token"].(string)
+			claims := tokenClaims(t, deps, at)
+			parsed, _, err := jwt.NewParser().ParseUnverified(at, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified: %v", err)
+			}
+			if typ := parsed.Header["typ"]; typ != tt.wantTyp {
+				t.Errorf("typ = %v, want %v", typ, tt.wantTyp)
+			}
+			if claims["aud"] != tt.wantAud {
+				t.Errorf("aud = %v, want %s", claims["aud"], tt.wantAud)
+			}
+			if claims["client_id"] != "svc" {
+				t.Errorf("client_id = %v, want svc", claims["client_id"])
+			}
+		})
+	}
+}
@@ -1,44 +1,56 @@
 package http
 
 import (
-	"crypto/rsa"
-	"jwtea/internal/core"
 	"net/http"
 
 	"jwtea/internal/callback"
 	"jwtea/internal/config"
+	"jwtea/internal/core"
 	"jwtea/internal/keys"
+	"jwtea/internal/pki"
 )
 
 type RouterConfig struct {
-	Store   *core.Store
-	Config  *config.Config
-	Chaos   *core.ChaosFlags
-	LogHub  *core.LogHub
-	Issuer  string
-	PrivKey *rsa.PrivateKey
-	Kid     string
-	JWK     keys.JwkRSA
+	Store  *core.Store
+	Config *config.Config
+	Chaos  *core.ChaosFlags
+	LogHub *core.LogHub
+	Issuer string
+	Keys   *keys.Store
+	CA     *pki.CA
 }
 
 func NewRouter(cfg RouterConfig) http.Handler {
 	mux := http.NewServeMux()
 
 	deps := &Dependencies{
-		Store:   cfg.Store,
-		Config:  cfg.Config,
-		Chaos:   cfg.Chaos,
-		Issuer:  cfg.Issuer,
-		PrivKey: cfg.PrivKey,
-		Kid:     cfg.Kid,
+		Store:  cfg.Store,
+		Config: cfg.Config,
+		Chaos:  cfg.Chaos,
+		LogHub: cfg.LogHub,
+		Issuer: cfg.Issuer,
+		Keys:   cfg.Keys,
+		CA:     cfg.CA,
 	}
 
 	mux.Handle("/", NewRootHandler())
 	mux.Handle("/healthz", NewHealthHandler())
-	mux.Handle("/jwks.json", NewJWKSHandler(cfg.JWK))
+	mux.Handle("/jwks.json", NewJWKSHandler(cfg.Keys))
 	mux.Handle("/.well-known/openid-configuration", NewDiscoveryHandler(cfg.Issuer, cfg.Config))
-	mux.Handle("/authorize", NewAuthorizeHandler(deps))
+	authorize := NewAuthorizeHandler(deps)
+	mux.Handle("/authorize", authorize)
+	mux.Handle("/authorize/login", authorize)
+	mux.Handle("/authorize/consent", authorize)
 	mux.Handle("/oauth2/token", NewTokenHandler(deps))
+	mux.Handle("/oauth2/par", NewPARHandler(deps))
+	mux.Handle("/oauth2/device_authorization", NewDeviceAuthorizationHandler(deps))
+	mux.Handle("/device", NewDeviceVerificationHandler(deps))
+	mux.Handle("/userinfo", NewUserInfoHandler(deps))
+	mux.Handle("/logout", NewLogoutHandler(deps))
+
+	adminKeys := NewAdminKeysHandler(deps)
+	mux.Handle("/admin/keys", adminKeys)
+	mux.Handle("/admin/keys/rotate", adminKeys)
 
 	if cfg.Config.Introspection.Enabled {
 		mux.Handle("/oauth2/introspect", NewIntrospectionHandler(deps))
@@ -48,6 +60,12 @@ func NewRouter(cfg RouterConfig) http.Handler {
 		mux.Handle("/oauth2/revoke", NewRevocationHandler(deps))
 	}
 
+	if cfg.Config.Registration.Enabled {
+		registration := NewRegistrationHandler(deps)
+		mux.Handle("/oauth2/register", registration)
+		mux.Handle("/oauth2/register/", registration)
+	}
+
 	if cfg.Config.CallbackServer.Enabled {
 		callbackHandler := callback.NewHandler(cfg.Config.CallbackServer, cfg.Issuer)
 		mux.Handle(cfg.Config.CallbackServer.Path, callbackHandler)
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+const sessionCookie = "jwtea_session"
+
+// currentSession returns the live session named by the request's cookie.
+func currentSession(s *core.Store, r *http.Request) (core.Session, bool) {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return core.Session{}, false
+	}
+	return s.GetSession(c.Value)
+}
+
+// startSession replaces the browser's session with a new one for userID.
+func (h *AuthorizeHandler) startSession(w http.ResponseWriter, r *http.Request, userID string, authTime time.Time) error {
+	if old, ok := currentSession(h.deps.Store, r); ok {
+		h.deps.Store.DeleteSession(old.ID)
+	}
+	id, err := RandCode(32)
+	if err != nil {
+		return err
+	}
+	sess := core.Session{
+		ID:        id,
+		UserID:    userID,
+		AuthTime:  authTime,
+		ExpiresAt: authTime.Add(h.deps.Config.Session.Lifetime.Duration),
+	}
+	h.deps.Store.SaveSession(sess)
+	http.SetCookie(w, newSessionCookie(h.deps.Issuer, sess.ID, sess.ExpiresAt))
+	return nil
+}
+
+// newSessionCookie builds the session cookie; an empty value clears it. It is
+// SameSite=None on HTTPS issuers so silent renew from another site's iframe
+// still sees it.
+func newSessionCookie(issuer, value string, expires time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if strings.HasPrefix(issuer, "https://") {
+		c.Secure = true
+		c.SameSite = http.SameSiteNoneMode
+	}
+	if value == "" {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+// reusableSession returns the browser's session if it satisfies the request:
+// no prompt=login, a matching login_hint and an auth_time within max_age.
+// maxAge is negative when the client sent none.
+func (h *AuthorizeHandler) reusableSession(r *http.Request, ar core.AuthRequest, maxAge int) (core.Session, bool) {
+	if hasPrompt(ar.Prompt, "login") {
+		return core.Session{}, false
+	}
+	sess, ok := currentSession(h.deps.Store, r)
+	if !ok {
+		return core.Session{}, false
+	}
+	if ar.LoginHint != "" && ar.LoginHint != sess.UserID {
+		return core.Session{}, false
+	}
+	if maxAge >= 0 && time.Since(sess.AuthTime) > time.Duration(maxAge)*time.Second {
+		return core.Session{}, false
+	}
+	if _, ok := h.deps.Store.GetUser(sess.UserID); !ok {
+		return core.Session{}, false
+	}
+	return sess, true
+}
+
+func hasPrompt(prompt, value string) bool {
+	return slices.Contains(strings.Fields(prompt), value)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"jwtea/internal/core"
+)
+
+func TestAuthorizePromptAndMaxAge(t *testing.T) {
+	tests := []struct {
+		name       string
+		sessionAge time.Duration // no session when zero
+		skip       bool          // the client skips consent
+		params     url.Values
+		// "code", "login" for the login page, or the expected error
+		want string
+	}{
+		{"prompt=none without session", 0, true, url.Values{"prompt": {"none"}}, "login_required"},
+		{"prompt=none with session", time.Minute, true, url.Values{"prompt": {"none"}}, "code"},
+		{"prompt=none without consent", time.Minute, false, url.Values{"prompt": {"none"}}, "consent_required"},
+		{"prompt=none for another user", time.Minute, true, url.Values{"prompt": {"none"}, "login_hint": {"bob@example.com"}}, "login_required"},
+		{"prompt=none past max_age", 10 * time.Minute, true, url.Values{"prompt": {"none"}, "max_age": {"60"}}, "login_required"},
+		{"within max_age", 10 * time.Minute, true, url.Values{"max_age": {"3600"}}, "code"},
+		{"past max_age", 10 * time.Minute, true, url.Values{"max_age": {"60"}}, "login"},
+		{"max_age=0", time.Minute, true, url.Values{"max_age": {"0"}}, "login"},
+		{"prompt=login", time.Minute, true, url.Values{"prompt": {"login"}}, "login"},
+		{"session reused", time.Minute, true, url.Values{}, "code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Config.Login.AutoLogin = false
+			deps.Store.AddUser(core.User{Email: "alice@example.com"})
+			deps.Store.AddClient(core.Client{ID: "web", RedirectURIs: []string{"https://rp.example.com/cb"}, SkipConsent: tt.skip})
+
+			q := url.Values{
+				"client_id":     {"web"},
+				"redirect_uri":  {"https://rp.example.com/cb"},
+				"response_type": {"code"},
+				"scope":         {"openid"},
+				"state":         {"xyz"},
+			}
+			for k, v := range tt.params {
+				q[k] = v
+			}
+			r := httptest.NewRequest(http.MethodGet, "/authorize?"+q.Encode(), nil)
+			authTime := time.Now().Add(-tt.sessionAge).Truncate(time.Second)
+			if tt.sessionAge != 0 {
+				deps.Store.SaveSession(core.Session{ID: "sess", UserID: "alice@example.com", AuthTime: authTime, ExpiresAt: time.Now().Add(time.Hour)})
+				r.AddCookie(&http.Cookie{Name: sessionCookie, Value: "sess"})
+			}
+			w := httptest.NewRecorder()
+			NewAuthorizeHandler(deps).ServeHTTP(w, r)
+
+			if tt.want == "login" {
+				if w.Code != http.StatusOK || w.Header().Get("Location") != "" {
+					t.Fatalf("status %d, Location %q, want the login page", w.Code, w.Header().Get("Location"))
+				}
+				return
+			}
+			if w.Code != http.StatusFound {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			loc, _ := url.Parse(w.Header().Get("Location"))
+			params := loc.Query()
+			if params.Get("state") != "xyz" {
+				t.Errorf("state = %q, want xyz", params.Get("state"))
+			}
+			if tt.want != "code" {
+				if got := params.Get("error"); got != tt.want {
+					t.Errorf("error = %q, want %q", got, tt.want)
+				}
+				return
+			}
+			ac, ok := deps.Store.ConsumeCode(params.Get("code"))
+			if !ok {
+				t.Fatalf("no authorization code in %s", loc)
+			}
+			if !ac.AuthTime.Equal(authTime) {
+				t.Errorf("auth_time %v, want the session's %v", ac.AuthTime, authTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"jwtea/internal/core"
+)
+
+// UserInfoHandler handles /userinfo endpoint (OIDC Core 5.3)
+type UserInfoHandler struct {
+	deps *Dependencies
+}
+
+func NewUserInfoHandler(deps *Dependencies) *UserInfoHandler {
+	return &UserInfoHandler{deps: deps}
+}
+
+func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tokenStr, scheme, ok := accessToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := core.ParseAndValidateToken(tokenStr, h.deps.Keys)
+	if err != nil {
+		writeTokenError(w, scheme, http.StatusUnauthorized, "invalid_token", "access token invalid or expired")
+		return
+	}
+	if jti, ok := claims["jti"].(string); ok && h.deps.Store.IsAccessTokenRevoked(jti) {
+		writeTokenError(w, scheme, http.StatusUnauthorized, "invalid_token", "access token revoked")
+		return
+	}
+
+	// A DPoP-bound token is only good with a proof from its key (RFC 9449
+	// section 7).
+	cnf, _ := claims["cnf"].(map[string]any)
+	jkt, _ := cnf["jkt"].(string)
+	switch {
+	case scheme == "DPoP":
+		proofJKT, err := verifyDPoPProof(h.deps, r, tokenStr)
+		if errors.Is(err, errUseDPoPNonce) {
+			issueDPoPNonce(h.deps, w)
+			writeDPoPError(w, "use_dpop_nonce", "use the nonce from the DPoP-Nonce header")
+			return
+		}
+		if err != nil {
+			writeDPoPError(w, "invalid_dpop_proof", err.Error())
+			return
+		}
+		if jkt == "" || proofJKT != jkt {
+			writeDPoPError(w, "invalid_token", "access token is not bound to the DPoP proof key")
+			return
+		}
+	case jkt != "":
+		writeDPoPError(w, "invalid_token", "DPoP-bound access token requires the DPoP scheme")
+		return
+	}
+	if x5t, _ := cnf["x5t#S256"].(string); x5t != "" && x5t != certThumbprint(r) {
+		writeTokenError(w, scheme, http.StatusUnauthorized, "invalid_token", "access token is bound to another client certificate")
+		return
+	}
+
+	scope, _ := claims["scope"].(string)
+	if !HasScope(scope, "openid") {
+		writeTokenError(w, scheme, http.StatusForbidden, "insufficient_scope", "openid scope required")
+		return
+	}
+
+	sub, _ := claims["sub"].(string)
+	user, ok := h.deps.Store.GetUser(sub)
+	if !ok {
+		writeTokenError(w, scheme, http.StatusUnauthorized, "invalid_token", "token subject is not a known user")
+		return
+	}
+
+	resp := core.UserClaims(user, scope)
+	resp["sub"] = sub
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, resp)
+}
+
+// accessToken returns the access token and its scheme, Bearer or DPoP, from
+// the Authorization header or, for form-encoded POSTs, the access_token body
+// parameter (RFC 6750).
+func accessToken(r *http.Request) (string, string, bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, token, found := strings.Cut(auth, " ")
+		if !found || token == "" {
+			return "", "", false
+		}
+		switch {
+		case strings.EqualFold(scheme, "Bearer"):
+			return strings.TrimSpace(token), "Bearer", true
+		case strings.EqualFold(scheme, "DPoP"):
+			return strings.TrimSpace(token), "DPoP", true
+		}
+		return "", "", false
+	}
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil {
+			if token := r.PostForm.Get("access_token"); token != "" {
+				return token, "Bearer", true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// writeTokenError rejects the access token, challenging with the scheme it
+// was presented with so that a DPoP client is asked for DPoP again.
+func writeTokenError(w http.ResponseWriter, scheme string, status int, code, desc string) {
+	if scheme == "DPoP" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`DPoP algs=%q, error=%q, error_description=%q`, strings.Join(dpopAlgorithms(), " "), code, desc))
+	} else {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="userinfo", error=%q, error_description=%q`, code, desc))
+	}
+	WriteOAuthErrorJSON(w, status, code, desc)
+}
+
+func writeDPoPError(w http.ResponseWriter, code, desc string) {
+	writeTokenError(w, "DPoP", http.StatusUnauthorized, code, desc)
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"jwtea/internal/core"
+	"jwtea/internal/keys"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestUserInfoChallengeScheme(t *testing.T) {
+	k := newDPoPKey(t)
+	tests := []struct {
+		name       string
+		scheme     string
+		token      func(deps *Dependencies) string
+		wantStatus int
+		wantScheme string
+	}{
+		{"bearer, invalid token", "Bearer", func(*Dependencies) string { return "junk" }, http.StatusUnauthorized, "Bearer"},
+		{"DPoP, invalid token", "DPoP", func(*Dependencies) string { return "junk" }, http.StatusUnauthorized, "DPoP"},
+		{"bearer, no openid scope", "Bearer", func(deps *Dependencies) string {
+			return issueToken(t, deps, core.TokenRequest{Subject: "alice@example.com", Scope: "profile"})
+		}, http.StatusForbidden, "Bearer"},
+		{"DPoP, no openid scope", "DPoP", func(deps *Dependencies) string {
+			jkt, _ := keys.Thumbprint(k.JWK())
+			return issueToken(t, deps, core.TokenRequest{Subject: "alice@example.com", Scope: "profile", JKT: jkt})
+		}, http.StatusForbidden, "DPoP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddUser(core.User{Email: "alice@example.com"})
+			token := tt.token(deps)
+
+			r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			r.Header.Set("Authorization", tt.scheme+" "+token)
+			if tt.scheme == "DPoP" {
+				r.Header.Set("DPoP", dpopProof(t, k, "GET", testIssuer+"/userinfo", func(_ map[string]any, c jwt.MapClaims) {
+					c["ath"] = athOf(token)
+				}))
+			}
+			w := httptest.NewRecorder()
+			NewUserInfoHandler(deps).ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if challenge := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, tt.wantScheme+" ") {
+				t.Errorf("WWW-Authenticate = %q, want the %s scheme", challenge, tt.wantScheme)
+			}
+		})
+	}
+}
+
+func TestUserInfoScopeClaims(t *testing.T) {
+	alice := core.User{
+		Email:             "alice@example.com",
+		Name:              "Alice Liddell",
+		GivenName:         "Alice",
+		FamilyName:        "Liddell",
+		PreferredUsername: "alice",
+		EmailVerified:     true,
+		PhoneNumber:       "+1 555 0100",
+		Address:           "1 Rabbit Hole, Oxford",
+	}
+	tests := []struct {
+		name  string
+		user  core.User
+		scope string
+		want  []string
+	}{
+		{"openid only", alice, "openid", []string{"sub"}},
+		{"profile", alice, "openid profile", []string{"sub", "name", "given_name", "family_name", "preferred_username"}},
+		{"email", alice, "openid email", []string{"sub", "email", "email_verified"}},
+		{"phone and address", alice, "openid phone address", []string{"sub", "phone_number", "phone_number_verified", "address"}},
+		{"empty attributes left out", core.User{Email: "bob@example.com"}, "openid profile phone address", []string{"sub"}},
+		{"unknown scope", alice, "openid payments", []string{"sub"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps := newTestDeps(t)
+			deps.Store.AddUser(tt.user)
+			token := issueToken(t, deps, core.TokenRequest{Subject: tt.user.Email, Scope: tt.scope})
+
+			r := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			NewUserInfoHandler(deps).ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d, body %s", w.Code, w.Body)
+			}
+			got := decodeJSON(t, w)
+			if got["sub"] != tt.user.Email {
+				t.Errorf("sub = %v, want %s", got["sub"], tt.user.Email)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("claims %v, want %v", got, tt.want)
+			}
+			for _, name := range tt.want {
+				if _, ok := got[name]; !ok {
+					t.Errorf("claim %s missing from %v", name, got)
+				}
+			}
+		})
+	}
+}
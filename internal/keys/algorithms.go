@@ -0,0 +1,130 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"sort"
+)
+
+// Algorithm describes a JWS signing algorithm and the kind of key it needs.
+type Algorithm struct {
+	Name  string
+	Kty   string
+	Curve string
+	Hash  crypto.Hash
+}
+
+var algorithms = map[string]Algorithm{
+	"RS256": {Name: "RS256", Kty: "RSA", Hash: crypto.SHA256},
+	"RS384": {Name: "RS384", Kty: "RSA", Hash: crypto.SHA384},
+	"RS512": {Name: "RS512", Kty: "RSA", Hash: crypto.SHA512},
+	"PS256": {Name: "PS256", Kty: "RSA", Hash: crypto.SHA256},
+	"PS384": {Name: "PS384", Kty: "RSA", Hash: crypto.SHA384},
+	"PS512": {Name: "PS512", Kty: "RSA", Hash: crypto.SHA512},
+	"ES256": {Name: "ES256", Kty: "EC", Curve: "P-256", Hash: crypto.SHA256},
+	"ES384": {Name: "ES384", Kty: "EC", Curve: "P-384", Hash: crypto.SHA384},
+	"EdDSA": {Name: "EdDSA", Kty: "OKP", Curve: "Ed25519", Hash: crypto.SHA512},
+	"HS256": {Name: "HS256", Kty: "oct", Hash: crypto.SHA256},
+	"HS384": {Name: "HS384", Kty: "oct", Hash: crypto.SHA384},
+	"HS512": {Name: "HS512", Kty: "oct", Hash: crypto.SHA512},
+}
+
+func LookupAlgorithm(name string) (Algorithm, error) {
+	alg, ok := algorithms[name]
+	if !ok {
+		return Algorithm{}, fmt.Errorf("unsupported signing algorithm %q", name)
+	}
+	return alg, nil
+}
+
+func SupportedAlgorithms() []string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Symmetric reports whether the algorithm signs with a shared secret.
+func (a Algorithm) Symmetric() bool {
+	return a.Kty == "oct"
+}
+
+// Fits reports whether priv can sign with this algorithm.
+func (a Algorithm) Fits(priv any) bool {
+	kty, crv := keyType(priv)
+	return kty == a.Kty && crv == a.Curve
+}
+
+// Generate creates a new key for the algorithm.
+func (a Algorithm) Generate() (*Key, error) {
+	var priv any
+	var err error
+	switch a.Kty {
+	case "RSA":
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EC":
+		priv, err = ecdsa.GenerateKey(curveByName(a.Curve), rand.Reader)
+	case "OKP":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case "oct":
+		secret := make([]byte, a.Hash.Size())
+		_, err = rand.Read(secret)
+		priv = secret
+	default:
+		err = fmt.Errorf("unsupported key type %q", a.Kty)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate %s key: %w", a.Name, err)
+	}
+	return NewKey(priv, a.Name)
+}
+
+// defaultAlgorithm picks the algorithm to use for a key that does not say.
+func defaultAlgorithm(priv any) (string, bool) {
+	switch kty, crv := keyType(priv); {
+	case kty == "RSA":
+		return "RS256", true
+	case kty == "EC" && crv == "P-256":
+		return "ES256", true
+	case kty == "EC" && crv == "P-384":
+		return "ES384", true
+	case kty == "OKP":
+		return "EdDSA", true
+	case kty == "oct":
+		return "HS256", true
+	}
+	return "", false
+}
+
+func keyType(priv any) (kty, crv string) {
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		return "RSA", ""
+	case *ecdsa.PrivateKey:
+		return "EC", k.Curve.Params().Name
+	case ed25519.PrivateKey:
+		return "OKP", "Ed25519"
+	case []byte:
+		return "oct", ""
+	}
+	return "", ""
+}
+
+func curveByName(name string) elliptic.Curve {
+	switch name {
+	case "P-256":
+		return elliptic.P256()
+	case "P-384":
+		return elliptic.P384()
+	case "P-521":
+		return elliptic.P521()
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// privateKeyFromJWK rebuilds the private key described by j.
+func privateKeyFromJWK(j JWK) (any, error) {
+	switch j.Kty {
+	case "RSA":
+		return rsaPrivateKeyFromJWK(j)
+	case "EC":
+		curve := curveByName(j.Crv)
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported EC curve %q", j.Crv)
+		}
+		d, err := b64Bytes(j.D)
+		if err != nil {
+			return nil, err
+		}
+		return ecdsa.ParseRawPrivateKey(curve, d)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", j.Crv)
+		}
+		seed, err := b64Bytes(j.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	case "oct":
+		secret, err := b64Bytes(j.K)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("empty HMAC secret")
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("unsupported JWK kty %q", j.Kty)
+}
+
+// PublicKeyFromJWK returns the public key described by j, such as a key from a
+// client's JWKS.
+func PublicKeyFromJWK(j JWK) (any, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := b64Int(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64Int(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve := curveByName(j.Crv)
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported EC curve %q", j.Crv)
+		}
+		x, err := b64Bytes(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64Bytes(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC public key")
+		}
+		point := append([]byte{4}, append(x, y...)...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", j.Crv)
+		}
+		x, err := b64Bytes(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported JWK kty %q", j.Kty)
+}
+
+func rsaPrivateKeyFromJWK(j JWK) (*rsa.PrivateKey, error) {
+	if j.D == "" || j.P == "" || j.Q == "" {
+		return nil, errors.New("JWK is not an RSA private key")
+	}
+	var ints [5]*big.Int
+	for i, member := range []string{j.N, j.E, j.D, j.P, j.Q} {
+		v, err := b64Int(member)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+
+	pk := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: ints[0], E: int(ints[1].Int64())},
+		D:         ints[2],
+		Primes:    []*big.Int{ints[3], ints[4]},
+	}
+	if err := pk.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid RSA key: %w", err)
+	}
+	pk.Precompute()
+	return pk, nil
+}
+
+// Thumbprint computes the RFC 7638 SHA-256 thumbprint of a public JWK,
+// base64url encoded, as used by DPoP's jkt.
+func Thumbprint(j JWK) (string, error) {
+	var members string
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, j.Crv, j.X, j.Y)
+	case "OKP":
+		members = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, j.Crv, j.X)
+	default:
+		return "", fmt.Errorf("unsupported JWK kty %q", j.Kty)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -1,49 +1,199 @@
 package keys
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
-	"log"
+	"fmt"
 	"math/big"
+	"time"
 )
 
-type JwkRSA struct {
+// JWK is a JSON Web Key. Private members are only filled in when a key is
+// written to disk; keys served from /jwks.json carry public members only.
+type JWK struct {
 	Kty string `json:"kty"`
-	Use string `json:"use"`
-	Alg string `json:"alg"`
-	Kid string `json:"kid"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	K   string `json:"k,omitempty"`
 }
 
-func MustGenerateRSA() (*rsa.PrivateKey, string, JwkRSA) {
-	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+type KeyStatus string
+
+const (
+	// StatusNext keys are published but do not sign yet.
+	StatusNext KeyStatus = "next"
+	// StatusActive is the key that signs new tokens.
+	StatusActive KeyStatus = "active"
+	// StatusRetired keys no longer sign but stay published so tokens they
+	// signed keep validating.
+	StatusRetired KeyStatus = "retired"
+)
+
+// Key is a signing key together with where it came from. Private is one of
+// *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey or []byte (HMAC).
+type Key struct {
+	ID        string
+	Alg       string
+	Private   any
+	CreatedAt time.Time
+	Path      string
+	Status    KeyStatus
+	RetiredAt time.Time
+}
+
+func NewKey(priv any, alg string) (*Key, error) {
+	a, err := LookupAlgorithm(alg)
 	if err != nil {
-		log.Fatalf("generate RSA key: %v", err)
+		return nil, err
 	}
-	pub := &pk.PublicKey
+	if !a.Fits(priv) {
+		return nil, fmt.Errorf("key of type %T cannot sign %s", priv, alg)
+	}
+	kid, err := thumbprint(priv)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		ID:        kid,
+		Alg:       alg,
+		Private:   priv,
+		CreatedAt: time.Now(),
+	}, nil
+}
 
-	spki, err := x509.MarshalPKIXPublicKey(pub)
+// Generate creates a new key for the named algorithm.
+func Generate(alg string) (*Key, error) {
+	a, err := LookupAlgorithm(alg)
 	if err != nil {
-		log.Fatalf("marshal public key: %v", err)
+		return nil, err
+	}
+	return a.Generate()
+}
+
+// Algorithm returns the key's signing algorithm.
+func (k *Key) Algorithm() Algorithm {
+	a, _ := LookupAlgorithm(k.Alg)
+	return a
+}
+
+// VerificationKey returns the key material that verifies the key's signatures.
+func (k *Key) VerificationKey() any {
+	switch priv := k.Private.(type) {
+	case *rsa.PrivateKey:
+		return &priv.PublicKey
+	case *ecdsa.PrivateKey:
+		return &priv.PublicKey
+	case ed25519.PrivateKey:
+		return priv.Public()
+	}
+	return k.Private
+}
+
+// JWK returns the public JWK for the key. For an HMAC key it carries no key
+// material, so it is only a base for PrivateJWK and is never published.
+func (k *Key) JWK() JWK {
+	j := JWK{Use: "sig", Alg: k.Alg, Kid: k.ID}
+	switch priv := k.Private.(type) {
+	case *rsa.PrivateKey:
+		j.Kty = "RSA"
+		j.N = b64(priv.N.Bytes())
+		j.E = b64(big.NewInt(int64(priv.E)).Bytes())
+	case *ecdsa.PrivateKey:
+		j.Kty = "EC"
+		j.Crv = priv.Curve.Params().Name
+		point, _ := priv.PublicKey.Bytes()
+		size := (len(point) - 1) / 2
+		j.X = b64(point[1 : 1+size])
+		j.Y = b64(point[1+size:])
+	case ed25519.PrivateKey:
+		j.Kty = "OKP"
+		j.Crv = "Ed25519"
+		j.X = b64(priv.Public().(ed25519.PublicKey))
+	case []byte:
+		j.Kty = "oct"
+	}
+	return j
+}
+
+// PrivateJWK returns the JWK including private members.
+func (k *Key) PrivateJWK() (JWK, error) {
+	j := k.JWK()
+	switch priv := k.Private.(type) {
+	case *rsa.PrivateKey:
+		if len(priv.Primes) != 2 {
+			return JWK{}, fmt.Errorf("multi-prime RSA keys cannot be written as JWK")
+		}
+		j.D = b64(priv.D.Bytes())
+		j.P = b64(priv.Primes[0].Bytes())
+		j.Q = b64(priv.Primes[1].Bytes())
+	case *ecdsa.PrivateKey:
+		d, err := priv.Bytes()
+		if err != nil {
+			return JWK{}, err
+		}
+		j.D = b64(d)
+	case ed25519.PrivateKey:
+		j.D = b64(priv.Seed())
+	case []byte:
+		j.K = b64(priv)
+	}
+	return j, nil
+}
+
+// thumbprint derives a key ID from the public key. HMAC keys have no public
+// half, and a hash of the secret would leak into every token header, so they
+// get a random ID that is stored with the key.
+func thumbprint(priv any) (string, error) {
+	if _, ok := priv.([]byte); ok {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return "", fmt.Errorf("generate key ID: %w", err)
+		}
+		return b64(id), nil
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return "", fmt.Errorf("unsupported key type %T", priv)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return "", fmt.Errorf("marshal public key: %w", err)
 	}
 	sum := sha256.Sum256(spki)
-	kid := base64.RawURLEncoding.EncodeToString(sum[:])
+	return b64(sum[:]), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
 
-	n := base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
-	eInt := big.NewInt(int64(pub.E))
-	e := base64.RawURLEncoding.EncodeToString(eInt.Bytes())
+func b64Int(s string) (*big.Int, error) {
+	b, err := b64Bytes(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
 
-	jwk := JwkRSA{
-		Kty: "RSA",
-		Use: "sig",
-		Alg: "RS256",
-		Kid: kid,
-		N:   n,
-		E:   e,
+func b64Bytes(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode JWK member: %w", err)
 	}
-	return pk, kid, jwk
+	return b, nil
 }
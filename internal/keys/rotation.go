@@ -0,0 +1,227 @@
+package keys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFile records which key in the directory is active, which one is next
+// and when the others were retired.
+const stateFile = "keyset.json"
+
+type keysetState struct {
+	Active  string               `json:"active,omitempty"`
+	Next    string               `json:"next,omitempty"`
+	Retired map[string]time.Time `json:"retired,omitempty"`
+}
+
+// Rotate makes the next key the signing key, retires the previous one and
+// stages a fresh next key so it is published before it is used. Without a
+// staged key, a new one is generated and activated straight away.
+func (s *Store) Rotate() (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promoted := s.next
+	if promoted == nil {
+		k, err := s.generateLocked()
+		if err != nil {
+			return nil, err
+		}
+		promoted = k
+	}
+
+	now := time.Now()
+	if s.active != nil {
+		s.active.Status = StatusRetired
+		s.active.RetiredAt = now
+	}
+	promoted.Status = StatusActive
+	s.active = promoted
+	s.next = nil
+
+	if err := s.stageNextLocked(); err != nil {
+		return promoted, err
+	}
+	s.pruneLocked(now)
+	return promoted, s.saveState()
+}
+
+// PrepareNext stages a next key if there is none yet.
+func (s *Store) PrepareNext() (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next == nil {
+		if err := s.stageNextLocked(); err != nil {
+			return nil, err
+		}
+		if err := s.saveState(); err != nil {
+			return s.next, err
+		}
+	}
+	return s.next, nil
+}
+
+// Prune drops retired keys whose grace period is over and deletes their files.
+func (s *Store) Prune() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.pruneLocked(time.Now())
+	if n > 0 {
+		_ = s.saveState()
+	}
+	return n
+}
+
+// RotateEvery rotates the signing key every interval until stop is closed.
+// report is called after each rotation.
+func (s *Store) RotateEvery(interval time.Duration, stop <-chan struct{}, report func(*Key, error)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			k, err := s.Rotate()
+			if report != nil {
+				report(k, err)
+			}
+		}
+	}
+}
+
+func (s *Store) generateLocked() (*Key, error) {
+	k, err := Generate(s.alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(k); err != nil {
+		return nil, err
+	}
+	s.keys = append(s.keys, k)
+	return k, nil
+}
+
+func (s *Store) stageNextLocked() error {
+	k, err := s.generateLocked()
+	if err != nil {
+		return err
+	}
+	k.Status = StatusNext
+	s.next = k
+	return nil
+}
+
+func (s *Store) pruneLocked(now time.Time) int {
+	kept := s.keys[:0]
+	pruned := 0
+	for _, k := range s.keys {
+		if s.published(k, now) {
+			kept = append(kept, k)
+			continue
+		}
+		if k.Path != "" {
+			_ = os.Remove(k.Path)
+		}
+		pruned++
+	}
+	s.keys = kept
+	return pruned
+}
+
+func (s *Store) loadState() (keysetState, error) {
+	var st keysetState
+	data, err := os.ReadFile(filepath.Join(s.dir, stateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("read key state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("decode key state: %w", err)
+	}
+	return st, nil
+}
+
+// applyState assigns statuses to freshly loaded keys. Keys the state does not
+// mention stay published as retired keys with no retirement time. Without a
+// recorded active key, the newest key for the store's algorithm signs.
+func (s *Store) applyState(st keysetState) {
+	byID := make(map[string]*Key, len(s.keys))
+	for _, k := range s.keys {
+		byID[k.ID] = k
+	}
+	for kid, at := range st.Retired {
+		if k, ok := byID[kid]; ok {
+			k.RetiredAt = at
+		}
+	}
+
+	if k, ok := byID[st.Active]; ok {
+		if k.Alg == s.alg {
+			s.active = k
+		} else {
+			k.RetiredAt = time.Now()
+		}
+	}
+	if s.active == nil {
+		for _, k := range s.keys {
+			if k.Alg == s.alg && k.RetiredAt.IsZero() && k.ID != st.Next {
+				s.active = k
+			}
+		}
+	}
+	if k, ok := byID[st.Next]; ok && k != s.active && k.Alg == s.alg {
+		s.next = k
+	}
+
+	if s.active != nil {
+		s.active.Status = StatusActive
+		s.active.RetiredAt = time.Time{}
+	}
+	if s.next != nil {
+		s.next.Status = StatusNext
+		s.next.RetiredAt = time.Time{}
+	}
+}
+
+func (s *Store) saveState() error {
+	if s.dir == "" {
+		return nil
+	}
+	st := keysetState{Retired: make(map[string]time.Time)}
+	if s.active != nil {
+		st.Active = s.active.ID
+	}
+	if s.next != nil {
+		st.Next = s.next.ID
+	}
+	for _, k := range s.keys {
+		if k.Status == StatusRetired && !k.RetiredAt.IsZero() {
+			st.Retired[k.ID] = k.RetiredAt
+		}
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal key state: %w", err)
+	}
+	path := filepath.Join(s.dir, stateFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("write key state: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("write key state: %w", err)
+	}
+	return nil
+}
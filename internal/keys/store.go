@@ -0,0 +1,334 @@
+package keys
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store holds the issuer's signing keys. When it is backed by a directory,
+// keys are loaded from *.pem and *.jwk files there and newly generated keys
+// are written back, so the key IDs survive restarts.
+type Store struct {
+	mu     sync.RWMutex
+	dir    string
+	alg    string
+	grace  time.Duration
+	keys   []*Key
+	active *Key
+	next   *Key
+}
+
+// LoadStore loads keys from dir and picks the signing key for alg. An empty
+// dir gives an in-memory store with a freshly generated key. If dir holds no
+// key for alg and generate is set, a key is generated and saved there.
+func LoadStore(dir, alg string, generate bool) (*Store, error) {
+	if _, err := LookupAlgorithm(alg); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, alg: alg}
+
+	if dir == "" {
+		k, err := Generate(alg)
+		if err != nil {
+			return nil, err
+		}
+		k.Status = StatusActive
+		s.keys = append(s.keys, k)
+		s.active = k
+		return s, nil
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if s.active != nil {
+		return s, nil
+	}
+	if !generate {
+		return nil, fmt.Errorf("no %s signing key found in %s", alg, dir)
+	}
+
+	k, err := Generate(alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(k); err != nil {
+		return nil, err
+	}
+	k.Status = StatusActive
+	s.keys = append(s.keys, k)
+	s.active = k
+	return s, s.saveState()
+}
+
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Algorithm returns the configured signing algorithm.
+func (s *Store) Algorithm() string {
+	return s.alg
+}
+
+// SetGracePeriod sets how long retired keys stay published.
+func (s *Store) SetGracePeriod(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grace = d
+}
+
+func (s *Store) GracePeriod() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.grace
+}
+
+func (s *Store) Active() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Next returns the key that becomes active on the next rotation, if any.
+func (s *Store) Next() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.next
+}
+
+// Get returns a published key by ID.
+func (s *Store) Get(kid string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	for _, k := range s.keys {
+		if k.ID == kid && s.published(k, now) {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// List returns a snapshot of all keys, next key first and retired keys last.
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, *k)
+	}
+	order := map[KeyStatus]int{StatusNext: 0, StatusActive: 1, StatusRetired: 2}
+	sort.SliceStable(out, func(i, j int) bool {
+		if order[out[i].Status] != order[out[j].Status] {
+			return order[out[i].Status] < order[out[j].Status]
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out
+}
+
+// JWKS returns the public keys to publish: the active key, the next key and
+// retired keys still inside their grace period. HMAC keys have no public part
+// and are left out.
+func (s *Store) JWKS() []JWK {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	out := make([]JWK, 0, len(s.keys))
+	for _, k := range s.keys {
+		if s.published(k, now) && !k.Algorithm().Symmetric() {
+			out = append(out, k.JWK())
+		}
+	}
+	return out
+}
+
+// published reports whether k is still served. Retired keys without a
+// retirement time were never retired by jwtea and stay published.
+func (s *Store) published(k *Key, now time.Time) bool {
+	if k.Status != StatusRetired || k.RetiredAt.IsZero() {
+		return true
+	}
+	return now.Before(k.RetiredAt.Add(s.grace))
+}
+
+func (s *Store) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read key dir: %w", err)
+	}
+
+	var loaded []*Key
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if ext != ".pem" && ext != ".jwk" {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		k, err := loadKeyFile(path, s.alg)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", path, err)
+		}
+		k.Status = StatusRetired
+		loaded = append(loaded, k)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].CreatedAt.Before(loaded[j].CreatedAt)
+	})
+	s.keys = loaded
+
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+	s.applyState(state)
+	return nil
+}
+
+// save writes k to the store's directory: HMAC secrets as a private JWK,
+// everything else as a PKCS#8 PEM file. In-memory stores keep keys in memory.
+func (s *Store) save(k *Key) error {
+	if s.dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("create key dir: %w", err)
+	}
+
+	var path string
+	var data []byte
+	if k.Algorithm().Symmetric() {
+		j, err := k.PrivateJWK()
+		if err != nil {
+			return err
+		}
+		data, err = json.MarshalIndent(j, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal JWK: %w", err)
+		}
+		path = filepath.Join(s.dir, k.ID+".jwk")
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+		if err != nil {
+			return fmt.Errorf("marshal private key: %w", err)
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		path = filepath.Join(s.dir, k.ID+".pem")
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("write key: %w", err)
+	}
+	k.Path = path
+	return nil
+}
+
+// loadKeyFile reads a private key. A JWK's own "alg" wins; otherwise the key
+// signs with preferredAlg when it fits, or with the default for its type.
+func loadKeyFile(path, preferredAlg string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var priv any
+	var alg, kid string
+	if strings.EqualFold(filepath.Ext(path), ".jwk") {
+		var j JWK
+		if err := json.Unmarshal(data, &j); err != nil {
+			return nil, fmt.Errorf("decode JWK: %w", err)
+		}
+		priv, err = privateKeyFromJWK(j)
+		alg, kid = j.Alg, j.Kid
+	} else {
+		priv, err = parsePEM(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if alg == "" {
+		if a, _ := LookupAlgorithm(preferredAlg); a.Fits(priv) {
+			alg = preferredAlg
+		} else if def, ok := defaultAlgorithm(priv); ok {
+			alg = def
+		} else {
+			return nil, fmt.Errorf("unsupported key type %T", priv)
+		}
+	}
+
+	k, err := NewKey(priv, alg)
+	if err != nil {
+		return nil, err
+	}
+	if kid != "" {
+		k.ID = kid
+	} else if _, ok := priv.([]byte); ok {
+		// Without a kid of its own, an HMAC key is named by its file so the
+		// ID stays the same across restarts.
+		k.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	k.Path = path
+	k.CreatedAt = info.ModTime()
+	return k, nil
+}
+
+func parsePEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// ParsePublicPEM parses a PEM encoded public key (PKIX or PKCS#1) or the
+// public key of a certificate.
+func ParsePublicPEM(data []byte) (any, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
@@ -0,0 +1,159 @@
+package keys
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJWKSPublishesOnlyPublicKeys(t *testing.T) {
+	tests := []struct {
+		alg     string
+		wantKty string
+	}{
+		{"RS256", "RSA"},
+		{"PS256", "RSA"},
+		{"ES256", "EC"},
+		{"EdDSA", "OKP"},
+		{"HS256", ""},
+		{"HS512", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			s, err := LoadStore("", tt.alg, false)
+			if err != nil {
+				t.Fatalf("LoadStore: %v", err)
+			}
+			s.SetGracePeriod(time.Hour)
+			if _, err := s.Rotate(); err != nil {
+				t.Fatalf("Rotate: %v", err)
+			}
+
+			jwks := s.JWKS()
+			if tt.wantKty == "" {
+				if len(jwks) != 0 {
+					t.Fatalf("published %d HMAC keys: %+v", len(jwks), jwks)
+				}
+				return
+			}
+			// Active, next and the retired key.
+			if len(jwks) != 3 {
+				t.Fatalf("published %d keys, want 3", len(jwks))
+			}
+			for _, j := range jwks {
+				if j.Kty != tt.wantKty || j.Kid == "" || j.Alg != tt.alg {
+					t.Errorf("unexpected JWK %+v", j)
+				}
+				if j.D != "" || j.K != "" || j.P != "" {
+					t.Errorf("JWK %s carries private members", j.Kid)
+				}
+			}
+		})
+	}
+}
+
+func TestHMACKeyID(t *testing.T) {
+	dir := t.TempDir()
+	s, err := LoadStore(dir, "HS256", true)
+	if err != nil {
+		t.Fatalf("LoadStore: %v", err)
+	}
+	k := s.Active()
+	sum := sha256.Sum256(k.Private.([]byte))
+	if k.ID == b64(sum[:]) {
+		t.Errorf("key ID %s is the hash of the secret", k.ID)
+	}
+
+	reloaded, err := LoadStore(dir, "HS256", false)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := reloaded.Active().ID; got != k.ID {
+		t.Errorf("key ID after reload %s, want %s", got, k.ID)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	tests := []struct {
+		name        string
+		alg         string
+		rotations   int
+		grace       time.Duration
+		wantFiles   int
+		wantRetired int
+	}{
+		{"generated key", "RS256", 0, time.Hour, 1, 0},
+		{"rotated once", "RS256", 1, time.Hour, 3, 1},
+		{"rotated twice", "ES256", 2, time.Hour, 4, 2},
+		{"HMAC rotated", "HS256", 1, time.Hour, 3, 1},
+		{"retired keys pruned after the grace period", "EdDSA", 2, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := LoadStore(dir, tt.alg, true)
+			if err != nil {
+				t.Fatalf("LoadStore: %v", err)
+			}
+			s.SetGracePeriod(tt.grace)
+			for range tt.rotations {
+				if _, err := s.Rotate(); err != nil {
+					t.Fatalf("Rotate: %v", err)
+				}
+			}
+			active := s.Active().ID
+			var next string
+			if k := s.Next(); k != nil {
+				next = k.ID
+			}
+
+			var st keysetState
+			data, err := os.ReadFile(filepath.Join(dir, stateFile))
+			if err != nil {
+				t.Fatalf("read %s: %v", stateFile, err)
+			}
+			if err := json.Unmarshal(data, &st); err != nil {
+				t.Fatalf("decode %s: %v", stateFile, err)
+			}
+			if st.Active != active || st.Next != next || len(st.Retired) != tt.wantRetired {
+				t.Errorf("%s = %+v, want active %s, next %q and %d retired", stateFile, st, active, next, tt.wantRetired)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.*"))
+			if n := len(files) - 1; n != tt.wantFiles {
+				t.Errorf("%d key files, want %d: %v", n, tt.wantFiles, files)
+			}
+
+			reloaded, err := LoadStore(dir, tt.alg, false)
+			if err != nil {
+				t.Fatalf("reload: %v", err)
+			}
+			reloaded.SetGracePeriod(tt.grace)
+			if got := reloaded.Active().ID; got != active {
+				t.Errorf("active key after reload %s, want %s", got, active)
+			}
+			var gotNext string
+			if k := reloaded.Next(); k != nil {
+				gotNext = k.ID
+			}
+			if gotNext != next {
+				t.Errorf("next key after reload %q, want %q", gotNext, next)
+			}
+			retired := 0
+			for _, k := range reloaded.List() {
+				if k.Status == StatusRetired {
+					retired++
+					if _, ok := st.Retired[k.ID]; !ok || k.RetiredAt.IsZero() {
+						t.Errorf("retired key %s lost its retirement time", k.ID)
+					}
+				}
+			}
+			if retired != tt.wantRetired {
+				t.Errorf("%d retired keys after reload, want %d", retired, tt.wantRetired)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package pages
+
+import (
+	"net/http"
+)
+
+// ScopeItem is one requested scope on the consent page.
+type ScopeItem struct {
+	Name        string
+	Description string
+	Required    bool
+	Checked     bool
+}
+
+// ConsentData is what the consent page shows for a signed-in user.
+type ConsentData struct {
+	RequestID string
+	ClientID  string
+	UserID    string
+	Scopes    []ScopeItem
+	// AuthorizationDetails are the requested authorization_details entries
+	// as indented JSON.
+	AuthorizationDetails []string
+}
+
+var scopeDescriptions = map[string]string{
+	"openid":         "Sign you in and learn your user identifier",
+	"profile":        "See your name, username, picture and locale",
+	"email":          "See your email address",
+	"phone":          "See your phone number",
+	"address":        "See your postal address",
+	"offline_access": "Keep access while you are signed out (refresh tokens)",
+}
+
+// ScopeDescription returns a human readable description of a scope.
+func ScopeDescription(scope string) string {
+	if d, ok := scopeDescriptions[scope]; ok {
+		return d
+	}
+	return "Access " + scope
+}
+
+var consentPage = page(`{{define "title"}}Authorize {{.ClientID}}{{end}}
+{{define "content"}}
+    <h1>Authorize access</h1>
+    <div class="subtitle"><span class="mono">{{.ClientID}}</span> is requesting access for <span class="mono">{{.UserID}}</span></div>
+
+    <form method="POST" action="/authorize/consent">
+        <input type="hidden" name="request_id" value="{{.RequestID}}">
+        <div class="section">
+            <div class="label">Requested permissions</div>
+            {{range .Scopes}}
+            <label class="option">
+                {{if .Required}}
+                <input type="checkbox" checked disabled>
+                <input type="hidden" name="scope" value="{{.Name}}">
+                {{else}}
+                <input type="checkbox" name="scope" value="{{.Name}}"{{if .Checked}} checked{{end}}>
+                {{end}}
+                <div>
+                    <div class="name mono">{{.Name}}</div>
+                    <div class="meta">{{.Description}}</div>
+                </div>
+            </label>
+            {{else}}
+            <div class="meta">No scopes requested.</div>
+            {{end}}
+        </div>
+        {{if .AuthorizationDetails}}
+        <div class="section">
+            <div class="label">Authorization details</div>
+            {{range .AuthorizationDetails}}
+            <pre class="mono meta">{{.}}</pre>
+            {{end}}
+        </div>
+        {{end}}
+        <div class="actions">
+            <button type="submit" name="action" value="approve">Allow</button>
+            <button type="submit" name="action" value="deny" class="secondary">Deny</button>
+        </div>
+    </form>
+{{end}}`)
+
+func RenderConsent(w http.ResponseWriter, status int, data ConsentData) {
+	render(w, status, consentPage, data)
+}
@@ -0,0 +1,89 @@
+package pages
+
+import (
+	"net/http"
+
+	"jwtea/internal/core"
+)
+
+// DeviceData is what the device verification page shows. Without a ClientID
+// it asks for a user code; Done is set once the request was approved or
+// denied.
+type DeviceData struct {
+	UserCode         string
+	ClientID         string
+	Scope            string
+	Users            []core.User
+	Selected         string
+	PasswordRequired bool
+	Error            string
+	Done             string
+}
+
+var devicePage = page(`{{define "title"}}Connect a device{{end}}
+{{define "content"}}
+    {{if eq .Done "approved"}}
+    <h1>Device connected</h1>
+    <div class="subtitle">Signed in as <span class="mono">{{.Selected}}</span>. You can return to your device.</div>
+    {{else if eq .Done "denied"}}
+    <h1>Request denied</h1>
+    <div class="subtitle">The device was not given access. You can close this page.</div>
+    {{else if .ClientID}}
+    <h1>Connect a device</h1>
+    <div class="subtitle"><span class="mono">{{.ClientID}}</span> wants access{{if .Scope}} with <span class="mono">{{.Scope}}</span>{{end}}</div>
+
+    {{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+
+    <form method="POST" action="/device">
+        <input type="hidden" name="user_code" value="{{.UserCode}}">
+        <div class="section">
+            <div class="label">Code</div>
+            <div class="name mono">{{.UserCode}}</div>
+            <div class="meta">Check that this matches the code shown on your device.</div>
+        </div>
+        <div class="section">
+            <div class="label">Choose a user</div>
+            {{range .Users}}
+            <label class="option">
+                <input type="radio" name="email" value="{{.Email}}"{{if eq .Email $.Selected}} checked{{end}}>
+                <div>
+                    <div class="name">{{if .Name}}{{.Name}}{{else}}{{.Email}}{{end}}</div>
+                    <div class="meta">{{.Email}}{{if .Role}} · {{.Role}}{{end}}{{if .Dept}} · {{.Dept}}{{end}}</div>
+                </div>
+            </label>
+            {{else}}
+            <div class="meta">No users configured. Add one in the Users tab.</div>
+            {{end}}
+        </div>
+        {{if .PasswordRequired}}
+        <div class="section">
+            <div class="label">Password</div>
+            <input type="password" name="password" autocomplete="current-password">
+        </div>
+        {{end}}
+        <div class="actions">
+            <button type="submit" name="action" value="approve">Allow</button>
+            <button type="submit" name="action" value="deny" class="secondary">Deny</button>
+        </div>
+    </form>
+    {{else}}
+    <h1>Connect a device</h1>
+    <div class="subtitle">Enter the code shown on your device.</div>
+
+    {{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+
+    <form method="GET" action="/device">
+        <div class="section">
+            <div class="label">Code</div>
+            <input type="text" name="user_code" value="{{.UserCode}}" placeholder="XXXX-XXXX" autocomplete="off" autofocus>
+        </div>
+        <div class="actions">
+            <button type="submit">Continue</button>
+        </div>
+    </form>
+    {{end}}
+{{end}}`)
+
+func RenderDevice(w http.ResponseWriter, status int, data DeviceData) {
+	render(w, status, devicePage, data)
+}
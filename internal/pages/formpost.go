@@ -0,0 +1,43 @@
+package pages
+
+import (
+	"net/http"
+)
+
+// FormPostData is an authorization response delivered with
+// response_mode=form_post: Params are POSTed to RedirectURI as soon as the
+// page loads.
+type FormPostData struct {
+	RedirectURI string
+	Params      []FormParam
+}
+
+type FormParam struct {
+	Name  string
+	Value string
+}
+
+var formPostPage = page(`{{define "title"}}Continuing…{{end}}
+{{define "content"}}
+    <h1>Continuing…</h1>
+    <div class="subtitle">Returning to the application.</div>
+
+    <form method="POST" action="{{.RedirectURI}}" id="response">
+        {{range .Params}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+        {{end}}
+        <noscript>
+            <div class="actions">
+                <button type="submit">Continue</button>
+            </div>
+        </noscript>
+    </form>
+    <script>
+        window.addEventListener("load", function () {
+            document.getElementById("response").submit();
+        });
+    </script>
+{{end}}`)
+
+func RenderFormPost(w http.ResponseWriter, data FormPostData) {
+	render(w, http.StatusOK, formPostPage, data)
+}
@@ -0,0 +1,57 @@
+package pages
+
+import (
+	"net/http"
+
+	"jwtea/internal/core"
+)
+
+// LoginData is what the login page shows for a pending authorization request.
+type LoginData struct {
+	RequestID        string
+	ClientID         string
+	Scope            string
+	Users            []core.User
+	Selected         string
+	PasswordRequired bool
+	Error            string
+}
+
+var loginPage = page(`{{define "title"}}Sign in{{end}}
+{{define "content"}}
+    <h1>Sign in</h1>
+    <div class="subtitle"><span class="mono">{{.ClientID}}</span> wants to sign you in{{if .Scope}} with <span class="mono">{{.Scope}}</span>{{end}}</div>
+
+    {{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+
+    <form method="POST" action="/authorize/login">
+        <input type="hidden" name="request_id" value="{{.RequestID}}">
+        <div class="section">
+            <div class="label">Choose a user</div>
+            {{range .Users}}
+            <label class="option">
+                <input type="radio" name="email" value="{{.Email}}"{{if eq .Email $.Selected}} checked{{end}}>
+                <div>
+                    <div class="name">{{if .Name}}{{.Name}}{{else}}{{.Email}}{{end}}</div>
+                    <div class="meta">{{.Email}}{{if .Role}} · {{.Role}}{{end}}{{if .Dept}} · {{.Dept}}{{end}}</div>
+                </div>
+            </label>
+            {{else}}
+            <div class="meta">No users configured. Add one in the Users tab.</div>
+            {{end}}
+        </div>
+        {{if .PasswordRequired}}
+        <div class="section">
+            <div class="label">Password</div>
+            <input type="password" name="password" autocomplete="current-password">
+        </div>
+        {{end}}
+        <div class="actions">
+            <button type="submit">Continue</button>
+        </div>
+    </form>
+{{end}}`)
+
+func RenderLogin(w http.ResponseWriter, status int, data LoginData) {
+	render(w, status, loginPage, data)
+}
@@ -0,0 +1,45 @@
+package pages
+
+import (
+	"net/http"
+)
+
+// LogoutData is what the signed-out page shows. The page loads each
+// front-channel logout URI in a hidden iframe and then continues to
+// RedirectURI, if any.
+type LogoutData struct {
+	UserID           string
+	FrontchannelURIs []string
+	RedirectURI      string
+}
+
+var logoutPage = page(`{{define "title"}}Signed out{{end}}
+{{define "content"}}
+    <h1>Signed out</h1>
+    <div class="subtitle">{{if .UserID}}<span class="mono">{{.UserID}}</span> is no longer signed in.{{else}}You are not signed in.{{end}}{{if not .RedirectURI}} You can close this window.{{end}}</div>
+
+    {{if .FrontchannelURIs}}
+    <div class="section">
+        <div class="label">Signing out of applications</div>
+        {{range .FrontchannelURIs}}
+        <div class="meta mono">{{.}}</div>
+        <iframe src="{{.}}" style="display:none"></iframe>
+        {{end}}
+    </div>
+    {{end}}
+
+    {{if .RedirectURI}}
+    <div class="actions">
+        <a href="{{.RedirectURI}}"><button type="button">Continue</button></a>
+    </div>
+    <script>
+        window.addEventListener("load", function () {
+            setTimeout(function () { window.location.href = {{.RedirectURI}}; }, 500);
+        });
+    </script>
+    {{end}}
+{{end}}`)
+
+func RenderLogout(w http.ResponseWriter, status int, data LogoutData) {
+	render(w, status, logoutPage, data)
+}
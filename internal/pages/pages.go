@@ -0,0 +1,111 @@
+package pages
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+)
+
+const layout = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{block "title" .}}JWTea{{end}}</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', sans-serif;
+            max-width: 560px;
+            margin: 40px auto;
+            padding: 20px;
+            line-height: 1.6;
+        }
+        h1 { color: #2d3748; margin-bottom: 8px; font-size: 24px; }
+        .subtitle { color: #718096; font-size: 14px; margin-bottom: 20px; }
+        .mono { font-family: 'Courier New', monospace; }
+        .section {
+            background: #f7fafc;
+            border: 1px solid #e2e8f0;
+            border-radius: 6px;
+            padding: 16px;
+            margin-bottom: 16px;
+        }
+        .label {
+            font-size: 12px;
+            font-weight: 600;
+            color: #718096;
+            text-transform: uppercase;
+            margin-bottom: 8px;
+        }
+        .option {
+            display: flex;
+            align-items: center;
+            gap: 12px;
+            background: white;
+            border: 1px solid #e2e8f0;
+            border-radius: 4px;
+            padding: 10px 12px;
+            margin-bottom: 8px;
+            cursor: pointer;
+        }
+        .option:hover { border-color: #4299e1; }
+        .option .name { font-weight: 600; color: #2d3748; }
+        .option .meta { font-size: 13px; color: #718096; }
+        input[type=password], input[type=text] {
+            width: 100%;
+            padding: 8px 12px;
+            border: 1px solid #e2e8f0;
+            border-radius: 4px;
+            font-size: 14px;
+        }
+        .error {
+            background: #fff5f5;
+            border: 1px solid #feb2b2;
+            border-radius: 6px;
+            padding: 12px 16px;
+            margin-bottom: 16px;
+            color: #c53030;
+            font-size: 14px;
+        }
+        .actions { display: flex; gap: 8px; }
+        button {
+            background: #4299e1;
+            color: white;
+            border: none;
+            padding: 8px 16px;
+            border-radius: 4px;
+            font-size: 14px;
+            cursor: pointer;
+        }
+        button:hover { background: #3182ce; }
+        button.secondary { background: #e2e8f0; color: #2d3748; }
+        button.secondary:hover { background: #cbd5e0; }
+        .footer {
+            margin-top: 32px;
+            padding-top: 16px;
+            border-top: 1px solid #e2e8f0;
+            font-size: 13px;
+            color: #718096;
+        }
+    </style>
+</head>
+<body>
+{{template "content" .}}
+    <div class="footer">JWTea test authorization server</div>
+</body>
+</html>`
+
+func page(content string) *template.Template {
+	t := template.Must(template.New("layout").Parse(layout))
+	return template.Must(t.Parse(content))
+}
+
+func render(w http.ResponseWriter, status int, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := t.Execute(w, data); err != nil {
+		log.Printf("render page: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+)
+
+// CA is the local certificate authority behind the mTLS listener. It issues
+// the listener's server certificate and client certificates for testing.
+// When it is backed by a directory the CA is loaded from ca.crt and ca.key
+// there, or generated and saved on first use, so issued certificates stay
+// trusted across restarts.
+type CA struct {
+	dir  string
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// LoadCA loads the CA from dir, generating it if dir holds none. An empty
+// dir gives an in-memory CA.
+func LoadCA(dir string) (*CA, error) {
+	ca := &CA{dir: dir}
+	if dir != "" {
+		cert, key, err := loadPair(filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key"))
+		if err == nil {
+			ca.cert, ca.key = cert, key
+			return ca, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("load CA: %w", err)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template(pkix.Name{CommonName: "jwtea local CA"}, caValidity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	ca.cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	ca.key = key
+
+	if dir != "" {
+		if _, _, err := writePair(dir, "ca", ca.cert, key); err != nil {
+			return nil, err
+		}
+	}
+	return ca, nil
+}
+
+func (ca *CA) Dir() string {
+	return ca.dir
+}
+
+// Certificate returns the CA certificate.
+func (ca *CA) Certificate() *x509.Certificate {
+	return ca.cert
+}
+
+// Pool returns a pool trusting only this CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// ServerCertificate issues a TLS server certificate for hosts, which may be
+// DNS names or IP addresses.
+func (ca *CA) ServerCertificate(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl, err := template(pkix.Name{CommonName: hosts[0]}, certValidity)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("create server certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key}, nil
+}
+
+// ClientCertificate issues a client certificate with subject CN=clientID
+// and clientID as a URI SAN when it parses as a URI.
+func (ca *CA) ClientCertificate(clientID string) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl, err := template(pkix.Name{CommonName: clientID}, certValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	if u, err := url.Parse(clientID); err == nil && u.IsAbs() {
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create client certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// IssueClientFiles issues a client certificate for clientID and writes it,
+// its key and the CA certificate to the clients directory under the CA's
+// directory (the working directory for an in-memory CA). It returns the
+// certificate and key paths.
+func (ca *CA) IssueClientFiles(clientID string) (*x509.Certificate, string, string, error) {
+	cert, key, err := ca.ClientCertificate(clientID)
+	if err != nil {
+		return nil, "", "", err
+	}
+	dir := "jwtea-clients"
+	if ca.dir != "" {
+		dir = filepath.Join(ca.dir, "clients")
+	}
+	certPath, keyPath, err := writePair(dir, url.PathEscape(clientID), cert, key)
+	if err != nil {
+		return nil, "", "", err
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	if err := os.WriteFile(filepath.Join(dir, "ca.crt"), caPEM, 0644); err != nil {
+		return nil, "", "", fmt.Errorf("write CA certificate: %w", err)
+	}
+	return cert, certPath, keyPath, nil
+}
+
+// Thumbprint is the base64url SHA-256 of a certificate's DER encoding, as
+// used by x5t#S256 (RFC 8705 section 3.1).
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func template(subject pkix.Name, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+func loadPair(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: unsupported key type %T", keyPath, pair.PrivateKey)
+	}
+	return pair.Leaf, key, nil
+}
+
+// writePair writes cert and key as <name>.crt and <name>.key in dir.
+func writePair(dir, name string, cert *x509.Certificate, key crypto.Signer) (string, string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", fmt.Errorf("create %s: %w", dir, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal private key: %w", err)
+	}
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return "", "", fmt.Errorf("write key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		return "", "", fmt.Errorf("write certificate: %w", err)
+	}
+	return certPath, keyPath, nil
+}
@@ -1,15 +1,15 @@
 package tui
 
 import (
-	"crypto/rsa"
 	"jwtea/internal/core"
 
 	"jwtea/internal/config"
+	"jwtea/internal/keys"
+	"jwtea/internal/pki"
 )
 
 type Context struct {
-	PrivKey       *rsa.PrivateKey
-	Kid           string
+	Keys          *keys.Store
 	Issuer        string
 	Store         *core.Store
 	Chaos         *core.ChaosFlags
@@ -17,6 +17,7 @@ type Context struct {
 	ServerRunning bool
 	Config        *config.Config
 	ConfigPath    string
+	CA            *pki.CA
 }
 
 type ContextConfig struct {
@@ -24,17 +25,16 @@ type ContextConfig struct {
 	Chaos         *core.ChaosFlags
 	LogHub        *core.LogHub
 	Store         *core.Store
-	PrivKey       *rsa.PrivateKey
-	Kid           string
+	Keys          *keys.Store
 	Issuer        string
 	ServerRunning bool
 	ConfigPath    string
+	CA            *pki.CA
 }
 
 func NewContext(cfg ContextConfig) *Context {
 	return &Context{
-		PrivKey:       cfg.PrivKey,
-		Kid:           cfg.Kid,
+		Keys:          cfg.Keys,
 		Issuer:        cfg.Issuer,
 		Store:         cfg.Store,
 		Chaos:         cfg.Chaos,
@@ -42,6 +42,7 @@ func NewContext(cfg ContextConfig) *Context {
 		ServerRunning: cfg.ServerRunning,
 		Config:        cfg.Config,
 		ConfigPath:    cfg.ConfigPath,
+		CA:            cfg.CA,
 	}
 }
 
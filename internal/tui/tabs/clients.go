@@ -20,9 +20,10 @@ type ClientsTab struct {
 	width  int
 	height int
 
-	showModal  bool
-	modalMode  string
-	originalID string
+	showModal     bool
+	modalMode     string
+	originalID    string
+	editingClient *core.Client
 
 	formID           string
 	formSecret       string
@@ -31,7 +32,8 @@ type ClientsTab struct {
 
 	focusedButton int
 
-	errorMsg string
+	errorMsg  string
+	statusMsg string
 
 	styleHeader       lipgloss.Style
 	styleClient       lipgloss.Style
@@ -113,20 +115,20 @@ func (t *ClientsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if t.focusedButton > 0 {
 				t.focusedButton--
 				if t.focusedButton == 0 {
-					t.focusedButton = 3
+					t.focusedButton = 4
 				}
 			}
 		case "right", "l":
 			if t.focusedButton == 0 && len(t.clients) > 0 {
 				t.focusedButton = 1
-			} else if t.focusedButton > 0 && t.focusedButton < 3 {
+			} else if t.focusedButton > 0 && t.focusedButton < 4 {
 				t.focusedButton++
-			} else if t.focusedButton == 3 {
+			} else if t.focusedButton == 4 {
 				t.focusedButton = 1
 			}
 		case "tab":
 			if t.focusedButton == 0 {
-				t.focusedButton = 3
+				t.focusedButton = 4
 			} else {
 				t.focusedButton = 0
 			}
@@ -140,6 +142,10 @@ func (t *ClientsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if t.focusedButton == 2 && len(t.clients) > 0 && t.cursor < len(t.clients) {
 				t.deleteClient(t.clients[t.cursor].ID)
 			}
+		case "c":
+			if t.focusedButton == 3 && len(t.clients) > 0 && t.cursor < len(t.clients) {
+				t.issueCertificate(t.clients[t.cursor])
+			}
 		case "enter", " ":
 			return t, t.handleButtonPress()
 		}
@@ -156,7 +162,7 @@ func (t *ClientsTab) View() string {
 
 	headerLeft := t.styleHeader.Render("OAuth Clients")
 	addButtonStyle := t.styleButton
-	if t.focusedButton == 3 {
+	if t.focusedButton == 4 {
 		addButtonStyle = t.styleButtonActive
 	}
 	headerRight := addButtonStyle.Render("[Add Client]")
@@ -197,29 +203,39 @@ func (t *ClientsTab) View() string {
 
 			editStyle := t.styleButton
 			delStyle := t.styleButton
+			certStyle := t.styleButton
 			if i == t.cursor {
 				switch t.focusedButton {
 				case 1:
 					editStyle = t.styleButtonActive
 				case 2:
 					delStyle = t.styleButtonActive
+				case 3:
+					certStyle = t.styleButtonActive
 				}
 			}
 
-			line := fmt.Sprintf("%s %s %s %s%s",
+			line := fmt.Sprintf("%s %s %s %s%s%s",
 				prefix,
 				style.Render(clientID),
 				style.Render(redirectInfo),
 				editStyle.Render("[Edit]"),
 				delStyle.Render("[Del]"),
+				certStyle.Render("[Cert]"),
 			)
 			b.WriteString(line)
 			b.WriteString("\n")
 		}
 	}
 
+	if t.statusMsg != "" {
+		b.WriteString("\n")
+		b.WriteString(t.statusMsg)
+		b.WriteString("\n")
+	}
+
 	b.WriteString("\n")
-	footer := lipgloss.NewStyle().Faint(true).Render("j/k navigate • enter activate • a add • e edit • d delete • g/G jump")
+	footer := lipgloss.NewStyle().Faint(true).Render("j/k navigate • enter activate • a add • e edit • d delete • c cert • g/G jump")
 	b.WriteString(footer)
 
 	return t.styleBorder.Render(b.String())
@@ -233,6 +249,7 @@ func (t *ClientsTab) Help() []string {
 		"  a           add client",
 		"  e           edit client (when Edit focused)",
 		"  d           delete client (when Del focused)",
+		"  c           issue mTLS client certificate (when Cert focused)",
 		"  g / G       jump to top/bottom",
 		"",
 		"Modal (Add/Edit):",
@@ -271,6 +288,10 @@ func (t *ClientsTab) handleButtonPress() tea.Cmd {
 			t.deleteClient(t.clients[t.cursor].ID)
 		}
 	case 3:
+		if len(t.clients) > 0 && t.cursor < len(t.clients) {
+			t.issueCertificate(t.clients[t.cursor])
+		}
+	case 4:
 		t.openAddModal()
 	}
 	return nil
@@ -290,6 +311,7 @@ func (t *ClientsTab) openEditModal(client core.Client) {
 	t.showModal = true
 	t.modalMode = "edit"
 	t.originalID = client.ID
+	t.editingClient = &client
 	t.formID = client.ID
 	t.formSecret = client.Secret
 	t.formRedirectURIs = strings.Join(client.RedirectURIs, ",")
@@ -375,11 +397,13 @@ func (t *ClientsTab) saveClient() tea.Cmd {
 		}
 	}
 
-	client := core.Client{
-		ID:           t.formID,
-		Secret:       t.formSecret,
-		RedirectURIs: redirectURIs,
+	var client core.Client
+	if t.modalMode == "edit" && t.editingClient != nil {
+		client = *t.editingClient
 	}
+	client.ID = t.formID
+	client.Secret = t.formSecret
+	client.RedirectURIs = redirectURIs
 
 	switch t.modalMode {
 	case "add":
@@ -420,6 +444,36 @@ func (t *ClientsTab) deleteClient(id string) {
 	t.focusedButton = 0
 }
 
+// issueCertificate issues an mTLS client certificate for client from the
+// local CA and writes it out with its key. A client without tls_client_auth
+// settings gets its subject DN set to the new certificate's, so it can
+// authenticate with it straight away.
+func (t *ClientsTab) issueCertificate(client core.Client) {
+	if t.ctx.CA == nil {
+		t.statusMsg = t.styleError.Render("mTLS is not enabled (set mtls.enabled)")
+		return
+	}
+
+	cert, certPath, keyPath, err := t.ctx.CA.IssueClientFiles(client.ID)
+	if err != nil {
+		t.statusMsg = t.styleError.Render(fmt.Sprintf("Failed to issue certificate: %v", err))
+		return
+	}
+
+	if client.TLSClientAuthSubjectDN == "" && client.TLSClientAuthSANDNS == "" && client.TLSClientAuthSANURI == "" &&
+		client.TLSClientAuthSANIP == "" && client.TLSClientAuthSANEmail == "" {
+		client.TLSClientAuthSubjectDN = cert.Subject.String()
+		t.ctx.Store.UpdateClient(client)
+		if err := t.ctx.AutoSave(); err != nil {
+			t.statusMsg = t.styleError.Render(fmt.Sprintf("Failed to save: %v", err))
+			return
+		}
+		t.refreshClients()
+	}
+
+	t.statusMsg = t.styleCursor.Render(fmt.Sprintf("Issued %s (key %s) for %s", certPath, keyPath, cert.Subject))
+}
+
 func (t *ClientsTab) refreshClients() {
 	if t.ctx.Store == nil {
 		t.clients = []core.Client{}
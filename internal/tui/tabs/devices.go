@@ -0,0 +1,177 @@
+package tabs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"jwtea/internal/core"
+	"jwtea/internal/tui"
+	"jwtea/internal/tui/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DevicesTab lists pending device authorization requests so they can be
+// approved or denied without a browser.
+type DevicesTab struct {
+	ctx    *tui.Context
+	cursor int
+	user   int
+
+	width  int
+	height int
+
+	statusMsg string
+
+	styleHeader lipgloss.Style
+	styleItem   lipgloss.Style
+	styleCursor lipgloss.Style
+	styleMuted  lipgloss.Style
+	styleBorder lipgloss.Style
+}
+
+func NewDevicesTab(ctx *tui.Context) *DevicesTab {
+	return &DevicesTab{
+		ctx:         ctx,
+		styleHeader: theme.Header,
+		styleItem:   theme.Text,
+		styleCursor: theme.Accent,
+		styleMuted:  theme.Muted,
+		styleBorder: theme.Border,
+	}
+}
+
+func (t *DevicesTab) Init() tea.Cmd {
+	return nil
+}
+
+func (t *DevicesTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch v := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.width = v.Width
+		t.height = v.Height
+	case tea.KeyMsg:
+		codes := t.ctx.Store.ListPendingDeviceCodes()
+		users := t.users()
+		switch v.String() {
+		case "up", "k":
+			if t.cursor > 0 {
+				t.cursor--
+			}
+		case "down", "j":
+			if t.cursor < len(codes)-1 {
+				t.cursor++
+			}
+		case "left", "h":
+			if len(users) > 0 {
+				t.user = (t.user - 1 + len(users)) % len(users)
+			}
+		case "right", "l", "u":
+			if len(users) > 0 {
+				t.user = (t.user + 1) % len(users)
+			}
+		case "a", "enter":
+			if t.cursor < len(codes) && t.user < len(users) {
+				dc := codes[t.cursor]
+				if t.ctx.Store.ApproveDeviceCode(dc.UserCode, users[t.user].Email) {
+					t.statusMsg = fmt.Sprintf("Approved %s as %s", dc.UserCode, users[t.user].Email)
+				}
+			}
+		case "d", "x":
+			if t.cursor < len(codes) {
+				dc := codes[t.cursor]
+				if t.ctx.Store.DenyDeviceCode(dc.UserCode) {
+					t.statusMsg = fmt.Sprintf("Denied %s", dc.UserCode)
+				}
+			}
+		}
+	}
+	return t, nil
+}
+
+func (t *DevicesTab) users() []core.User {
+	users := t.ctx.Store.ListUsers()
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Email < users[j].Email
+	})
+	if t.user >= len(users) {
+		t.user = 0
+	}
+	return users
+}
+
+func (t *DevicesTab) View() string {
+	codes := t.ctx.Store.ListPendingDeviceCodes()
+	users := t.users()
+	if t.cursor >= len(codes) {
+		t.cursor = max(len(codes)-1, 0)
+	}
+
+	var b strings.Builder
+
+	contentWidth := t.width - 10
+	if contentWidth < 60 {
+		contentWidth = 60
+	}
+
+	b.WriteString(t.styleHeader.Render("Pending Device Codes"))
+	b.WriteString("\n")
+	b.WriteString(strings.Repeat("─", contentWidth))
+	b.WriteString("\n")
+
+	approveAs := "no users"
+	if len(users) > 0 {
+		approveAs = users[t.user].Email
+	}
+	b.WriteString(t.styleMuted.Render("Approve as: "))
+	b.WriteString(t.styleCursor.Render("◀ " + approveAs + " ▶"))
+	b.WriteString("\n\n")
+
+	if len(codes) == 0 {
+		b.WriteString(t.styleMuted.Render("No pending device codes. POST to /oauth2/device_authorization to start one."))
+		b.WriteString("\n")
+	}
+	for i, dc := range codes {
+		prefix := " "
+		style := t.styleItem
+		if i == t.cursor {
+			prefix = "●"
+			style = t.styleCursor
+		}
+		remaining := time.Until(dc.ExpiresAt).Round(time.Second)
+		line := fmt.Sprintf("%s %s %s %s",
+			prefix,
+			style.Render(fmt.Sprintf("%-10s", dc.UserCode)),
+			style.Render(fmt.Sprintf("%-24s", truncate(dc.ClientID, 24))),
+			t.styleMuted.Render(fmt.Sprintf("%-30s expires in %s", truncate(dc.Scope, 30), remaining)),
+		)
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	if t.statusMsg != "" {
+		b.WriteString("\n")
+		b.WriteString(t.styleMuted.Render(t.statusMsg))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	footer := lipgloss.NewStyle().Faint(true).Render("j/k navigate • h/l choose user • a approve • d deny")
+	b.WriteString(footer)
+
+	return t.styleBorder.Render(b.String())
+}
+
+func (t *DevicesTab) Help() []string {
+	return []string{
+		"Devices Tab:",
+		"  j/k, ↑/↓    navigate device codes",
+		"  h/l, ←/→    choose the user to approve as",
+		"  a / enter   approve selected code",
+		"  d           deny selected code",
+		"",
+	}
+}
@@ -375,7 +375,7 @@ func (t *GenerateTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (t *GenerateTab) View() string {
 	var b strings.Builder
 
-	if t.ctx.PrivKey == nil {
+	if t.ctx.Keys == nil {
 		b.WriteString(t.styleError.Render("⚠ Token generation requires server mode"))
 		b.WriteString("\n")
 		b.WriteString(lipgloss.NewStyle().Faint(true).Render("Start with: jwtea serve --dashboard"))
@@ -608,7 +608,7 @@ func (t *GenerateTab) scrollToFocusedField() {
 }
 
 func (t *GenerateTab) handleButtonPress() tea.Cmd {
-	if t.ctx.PrivKey == nil {
+	if t.ctx.Keys == nil {
 		return nil
 	}
 
@@ -635,7 +635,7 @@ func (t *GenerateTab) handleButtonPress() tea.Cmd {
 
 	req := core.TokenRequest{
 		Subject:      user,
-		Audience:     clientID,
+		Audience:     []string{clientID},
 		Scope:        scopes,
 		ExpiresIn:    expiry,
 		CustomClaims: customClaims,
@@ -646,7 +646,7 @@ func (t *GenerateTab) handleButtonPress() tea.Cmd {
 		req.ChaosInvalidSignature = true
 	}
 
-	gen := core.NewTokenGenerator(t.ctx.PrivKey, t.ctx.Kid, t.ctx.Issuer)
+	gen := core.NewTokenGenerator(t.ctx.Keys, t.ctx.Issuer)
 	result, err := gen.Generate(req)
 	if err != nil {
 		return nil
@@ -164,7 +164,7 @@ func (t *LogsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case logMsg:
 		if core.LogEntry(v).Path != "/favicon.ico" {
 			le := core.LogEntry(v)
-			if !t.errorOnly || le.Status >= 400 {
+			if !t.errorOnly || le.Status >= 400 || le.Error != "" {
 				t.list.InsertItem(0, toLogItem(le))
 			}
 			if t.follow {
@@ -244,6 +244,12 @@ func (t *LogsTab) viewDetails() string {
 		kv("Path", decodedPath),
 		kv("User-Agent", e.UserAgent),
 	}
+	if e.Outbound {
+		content = append(content, kv("Direction", "outbound"))
+	}
+	if e.Error != "" {
+		content = append(content, kv("Error", e.Error))
+	}
 
 	box := t.styleDetailBox.Render(strings.Join(content, "\n"))
 	help := lipgloss.NewStyle().Faint(true).Render("\n  esc/enter back • c copy path")
@@ -259,7 +265,7 @@ func (t *LogsTab) rebuildList() {
 	snapshot := t.ctx.LogHub.Snapshot()
 	for i := len(snapshot) - 1; i >= 0; i-- {
 		e := snapshot[i]
-		if t.errorOnly && e.Status < 400 {
+		if t.errorOnly && e.Status < 400 && e.Error == "" {
 			continue
 		}
 		if e.Path == "/favicon.ico" {
@@ -297,6 +303,9 @@ func toLogItem(e core.LogEntry) list.Item {
 
 	ts := e.Time.Format("15:04:05.000")
 	method := styleMethod.Render(fmt.Sprintf("%-6s", e.Method))
+	if e.Outbound {
+		method = styleMethod.Render(fmt.Sprintf("→%-5s", e.Method))
+	}
 	statusStyled := fmt.Sprintf("%3d", e.Status)
 	switch e.Status / 100 {
 	case 2:
@@ -328,6 +337,9 @@ func toLogItem(e core.LogEntry) list.Item {
 	if meta == "" {
 		meta = "-"
 	}
+	if e.Error != "" {
+		ua = e.Error
+	}
 	desc := lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("%s • %s • %s", meta, ua, bytes))
 	return logListItem{title: title, desc: desc, entry: e}
 }
@@ -2,11 +2,12 @@ package tabs
 
 import (
 	"fmt"
-	"jwtea/internal/tui/theme"
 	"strings"
 	"time"
 
+	"jwtea/internal/keys"
 	"jwtea/internal/tui"
+	"jwtea/internal/tui/theme"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -31,6 +32,7 @@ type SettingsTab struct {
 	supportedScopes    string
 
 	errorMsg string
+	keysMsg  string
 
 	styleHeader   lipgloss.Style
 	styleKey      lipgloss.Style
@@ -88,6 +90,8 @@ func (t *SettingsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if t.ctx.Chaos != nil {
 				t.chaos500 = t.ctx.Chaos.ToggleSimulate500()
 			}
+		case "r":
+			t.rotateKeys()
 		}
 	}
 	return t, nil
@@ -112,13 +116,30 @@ func (t *SettingsTab) View() string {
 		b.WriteString("\n")
 		b.WriteString(kv("Issuer", t.ctx.Issuer))
 		b.WriteString("\n")
-		b.WriteString(kv("Key ID", t.ctx.Kid))
-		b.WriteString("\n")
+		if t.ctx.Keys != nil {
+			if k := t.ctx.Keys.Active(); k != nil {
+				b.WriteString(kv("Key ID", k.ID))
+				b.WriteString("\n")
+			}
+			keySource := "in-memory (regenerated on restart)"
+			if dir := t.ctx.Keys.Dir(); dir != "" {
+				keySource = dir
+			}
+			b.WriteString(kv("Key Store", keySource))
+			b.WriteString("\n")
+		}
 	} else {
 		b.WriteString(t.styleVal.Render("  Not running (standalone mode)"))
 		b.WriteString("\n")
 	}
 
+	if t.ctx.Keys != nil {
+		b.WriteString("\n")
+		b.WriteString(t.styleHeader.Render("Signing Keys"))
+		b.WriteString("\n\n")
+		b.WriteString(t.viewKeys())
+	}
+
 	b.WriteString("\n")
 	b.WriteString(t.styleHeader.Render("Token Configuration"))
 	b.WriteString("\n\n")
@@ -179,7 +200,7 @@ func (t *SettingsTab) View() string {
 	}
 
 	b.WriteString("\n")
-	footer := lipgloss.NewStyle().Faint(true).Render("e edit config • x expire token • s invalid sig • 5 500 errors")
+	footer := lipgloss.NewStyle().Faint(true).Render("e edit config • r rotate keys • x expire token • s invalid sig • 5 500 errors")
 	b.WriteString(footer)
 
 	return b.String()
@@ -189,6 +210,7 @@ func (t *SettingsTab) Help() []string {
 	return []string{
 		"Settings Tab:",
 		"  e    edit token configuration (changes auto-saved)",
+		"  r    rotate signing keys (next key becomes active)",
 		"  x    toggle expired token chaos (one-time)",
 		"  s    toggle invalid signature chaos",
 		"  5    toggle 500 error chaos",
@@ -201,6 +223,45 @@ func (t *SettingsTab) Help() []string {
 	}
 }
 
+func (t *SettingsTab) viewKeys() string {
+	var b strings.Builder
+	grace := t.ctx.Keys.GracePeriod()
+	for _, k := range t.ctx.Keys.List() {
+		status := string(k.Status)
+		style := t.styleKey
+		switch k.Status {
+		case keys.StatusActive:
+			style = t.styleCursor
+		case keys.StatusRetired:
+			if k.RetiredAt.IsZero() {
+				break
+			}
+			remaining := time.Until(k.RetiredAt.Add(grace))
+			if remaining <= 0 {
+				continue
+			}
+			status = fmt.Sprintf("retired, published %s more", remaining.Round(time.Second))
+		}
+		b.WriteString(fmt.Sprintf("  %s %s  %s\n", style.Render(fmt.Sprintf("%-8s", k.Alg)), t.styleVal.Render(k.ID), style.Render(status)))
+	}
+	if t.keysMsg != "" {
+		b.WriteString("\n  " + t.keysMsg + "\n")
+	}
+	return b.String()
+}
+
+func (t *SettingsTab) rotateKeys() {
+	if t.ctx.Keys == nil {
+		return
+	}
+	k, err := t.ctx.Keys.Rotate()
+	if err != nil {
+		t.keysMsg = t.styleError.Render(fmt.Sprintf("Rotation failed: %v", err))
+		return
+	}
+	t.keysMsg = t.styleCursor.Render("Now signing with " + k.ID)
+}
+
 func (t *SettingsTab) enterEditMode() {
 	if t.ctx.Config == nil {
 		return
@@ -27,8 +27,13 @@ type UsersTab struct {
 	formEmail      string
 	formRole       string
 	formDept       string
+	formPassword   string
+	clearPassword  bool
 	formFieldIndex int
 
+	consents      []core.Consent
+	consentCursor int
+
 	focusedButton int
 
 	errorMsg string
@@ -140,6 +145,10 @@ func (t *UsersTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if t.focusedButton == 2 && len(t.users) > 0 && t.cursor < len(t.users) {
 				t.deleteUser(t.users[t.cursor].Email)
 			}
+		case "c":
+			if t.cursor < len(t.users) {
+				t.openConsentsModal(t.users[t.cursor])
+			}
 		case "enter", " ":
 			return t, t.handleButtonPress()
 		}
@@ -199,6 +208,12 @@ func (t *UsersTab) View() string {
 				}
 				info += fmt.Sprintf("dept: %s", user.Dept)
 			}
+			if user.PasswordHash != "" {
+				if info != "" {
+					info += ", "
+				}
+				info += "password"
+			}
 			if info == "" {
 				info = "-"
 			}
@@ -233,7 +248,7 @@ func (t *UsersTab) View() string {
 	}
 
 	b.WriteString("\n")
-	footer := lipgloss.NewStyle().Faint(true).Render("j/k navigate • enter activate • a add • e edit • d delete • g/G jump")
+	footer := lipgloss.NewStyle().Faint(true).Render("j/k navigate • enter activate • a add • e edit • d delete • c consents • g/G jump")
 	b.WriteString(footer)
 
 	return t.styleBorder.Render(b.String())
@@ -247,6 +262,7 @@ func (t *UsersTab) Help() []string {
 		"  a           add user",
 		"  e           edit user (when Edit focused)",
 		"  d           delete user (when Del focused)",
+		"  c           show and revoke the user's consents",
 		"  g / G       jump to top/bottom",
 		"",
 		"Modal (Add/Edit):",
@@ -254,6 +270,11 @@ func (t *UsersTab) Help() []string {
 		"  shift+tab   previous field",
 		"  enter       save",
 		"  esc         cancel",
+		"",
+		"Consents:",
+		"  j/k         navigate clients",
+		"  d/enter     revoke consent",
+		"  esc         close",
 	}
 }
 
@@ -296,6 +317,8 @@ func (t *UsersTab) openAddModal() {
 	t.formEmail = ""
 	t.formRole = ""
 	t.formDept = ""
+	t.formPassword = ""
+	t.clearPassword = false
 	t.formFieldIndex = 0
 	t.errorMsg = ""
 }
@@ -307,24 +330,77 @@ func (t *UsersTab) openEditModal(user core.User) {
 	t.formEmail = user.Email
 	t.formRole = user.Role
 	t.formDept = user.Dept
+	t.formPassword = ""
+	t.clearPassword = false
 	t.formFieldIndex = 0
 	t.errorMsg = ""
 }
 
+func (t *UsersTab) openConsentsModal(user core.User) {
+	t.showModal = true
+	t.modalMode = "consents"
+	t.editingUser = &user
+	t.consentCursor = 0
+	t.errorMsg = ""
+	t.refreshConsents()
+}
+
+func (t *UsersTab) refreshConsents() {
+	t.consents = nil
+	if t.ctx.Store != nil && t.editingUser != nil {
+		t.consents = t.ctx.Store.ListConsents(t.editingUser.Email)
+	}
+	if t.consentCursor >= len(t.consents) {
+		t.consentCursor = max(len(t.consents)-1, 0)
+	}
+}
+
+func (t *UsersTab) handleConsentKeys(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "esc", "q":
+		t.showModal = false
+	case "up", "k":
+		if t.consentCursor > 0 {
+			t.consentCursor--
+		}
+	case "down", "j":
+		if t.consentCursor < len(t.consents)-1 {
+			t.consentCursor++
+		}
+	case "d", "enter":
+		if t.consentCursor < len(t.consents) {
+			c := t.consents[t.consentCursor]
+			t.ctx.Store.RevokeConsent(c.UserID, c.ClientID)
+			t.refreshConsents()
+		}
+	}
+	return t, nil
+}
+
 func (t *UsersTab) handleModalKeys(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if t.modalMode == "consents" {
+		return t.handleConsentKeys(key)
+	}
 	switch key.String() {
 	case "esc":
 		t.showModal = false
 		return t, nil
 	case "tab":
-		t.formFieldIndex = (t.formFieldIndex + 1) % 3
+		t.formFieldIndex = (t.formFieldIndex + 1) % 4
 	case "shift+tab":
 		t.formFieldIndex--
 		if t.formFieldIndex < 0 {
-			t.formFieldIndex = 2
+			t.formFieldIndex = 3
 		}
 	case "enter":
 		return t, t.saveUser()
+	case "ctrl+x":
+		// An empty password field keeps the current password, so removing
+		// it needs its own key.
+		if t.formFieldIndex == 3 && t.modalMode == "edit" {
+			t.formPassword = ""
+			t.clearPassword = !t.clearPassword
+		}
 	case "backspace":
 		t.deleteChar()
 	default:
@@ -343,6 +419,9 @@ func (t *UsersTab) addChar(char string) {
 		t.formRole += char
 	case 2:
 		t.formDept += char
+	case 3:
+		t.formPassword += char
+		t.clearPassword = false
 	}
 }
 
@@ -360,6 +439,10 @@ func (t *UsersTab) deleteChar() {
 		if len(t.formDept) > 0 {
 			t.formDept = t.formDept[:len(t.formDept)-1]
 		}
+	case 3:
+		if len(t.formPassword) > 0 {
+			t.formPassword = t.formPassword[:len(t.formPassword)-1]
+		}
 	}
 }
 
@@ -373,10 +456,23 @@ func (t *UsersTab) saveUser() tea.Cmd {
 		return nil
 	}
 
-	user := core.User{
-		Email: t.formEmail,
-		Role:  t.formRole,
-		Dept:  t.formDept,
+	var user core.User
+	if t.modalMode == "edit" && t.editingUser != nil {
+		user = *t.editingUser
+	}
+	user.Email = t.formEmail
+	user.Role = t.formRole
+	user.Dept = t.formDept
+	if t.clearPassword {
+		user.PasswordHash = ""
+	}
+	if t.formPassword != "" {
+		hash, err := core.HashPassword(t.formPassword)
+		if err != nil {
+			t.errorMsg = fmt.Sprintf("Failed to hash password: %v", err)
+			return nil
+		}
+		user.PasswordHash = hash
 	}
 
 	switch t.modalMode {
@@ -439,6 +535,10 @@ func (t *UsersTab) IsTextInputActive() bool {
 }
 
 func (t *UsersTab) viewModal() string {
+	if t.modalMode == "consents" {
+		return t.viewConsents()
+	}
+
 	var b strings.Builder
 
 	title := "Add User"
@@ -485,6 +585,24 @@ func (t *UsersTab) viewModal() string {
 		deptValue = "_"
 	}
 	b.WriteString(deptLabel + "  " + deptStyle.Render(deptValue))
+	b.WriteString("\n")
+
+	passwordLabel := "Password:"
+	passwordStyle := t.styleUser
+	if t.formFieldIndex == 3 {
+		passwordLabel = "Password: ▶"
+		passwordStyle = t.styleCursor
+	}
+	passwordValue := strings.Repeat("*", len(t.formPassword))
+	if passwordValue == "" {
+		passwordValue = "_"
+		if t.clearPassword {
+			passwordValue = "(removed)"
+		} else if t.modalMode == "edit" && t.editingUser != nil && t.editingUser.PasswordHash != "" {
+			passwordValue = "(unchanged)"
+		}
+	}
+	b.WriteString(passwordLabel + " " + passwordStyle.Render(passwordValue))
 	b.WriteString("\n\n")
 
 	if t.errorMsg != "" {
@@ -497,7 +615,44 @@ func (t *UsersTab) viewModal() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(lipgloss.NewStyle().Faint(true).Render("tab/shift+tab navigate • enter save • esc cancel"))
+	help := "tab/shift+tab navigate • enter save • esc cancel"
+	if t.modalMode == "edit" && t.formFieldIndex == 3 {
+		help = "tab/shift+tab navigate • ctrl+x remove password • enter save • esc cancel"
+	}
+	b.WriteString(lipgloss.NewStyle().Faint(true).Render(help))
+
+	return t.styleModal.Render(b.String())
+}
+
+func (t *UsersTab) viewConsents() string {
+	var b strings.Builder
+
+	b.WriteString(t.styleHeader.Render("Consents for " + t.editingUser.Email))
+	b.WriteString("\n\n")
+
+	if len(t.consents) == 0 {
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render("No remembered consents."))
+		b.WriteString("\n")
+	}
+	for i, c := range t.consents {
+		prefix := " "
+		style := t.styleUser
+		if i == t.consentCursor {
+			prefix = "●"
+			style = t.styleCursor
+		}
+		line := fmt.Sprintf("%s %s %s  %s",
+			prefix,
+			style.Render(fmt.Sprintf("%-20s", c.ClientID)),
+			style.Render(strings.Join(c.Scopes, " ")),
+			lipgloss.NewStyle().Faint(true).Render(c.GrantedAt.Format("2006-01-02 15:04")),
+		)
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(lipgloss.NewStyle().Faint(true).Render("j/k navigate • d/enter revoke • esc close"))
 
 	return t.styleModal.Render(b.String())
 }